@@ -7,7 +7,7 @@
 //
 // Usage:
 //
-//	go2go <command> [arguments]
+//	go2go [flags] <command> [arguments]
 //
 // The commands are:
 //
@@ -18,6 +18,64 @@
 //
 // A package is expected to contain .go2 files but no .go files.
 //
+// The flags are:
+//
+//	-keeplinedirectives
+//		honor //line directives found in .go2 files, so that the
+//		//line directives in the generated .go files refer to the
+//		original source named by them rather than to the .go2 files
+//	-tabs
+//		indent generated files with tabs (default true); if false,
+//		indent with spaces
+//	-tabwidth
+//		tab width of generated files (default 8)
+//	-typeargcomments
+//		follow each call of an instantiated function with a comment
+//		listing its type arguments, as in /* Map(int, string) */
+//	-splitdecls n
+//		write at most n instantiated declarations to each generated
+//		file; the rest go to foo.gen1.go, foo.gen2.go, and so on
+//	-splitbytes n
+//		like -splitdecls, but move instantiated declarations to
+//		foo.gen1.go and so on to keep each generated file at about
+//		n bytes at most; both limits may be given
+//	-validate
+//		type check the generated .go files, and report any errors
+//		in them as translation failures
+//	-generate cmd
+//		add a //go:generate directive to each generated .go file
+//		that runs cmd on the .go2 file, as in
+//		//go:generate go2go translate foo.go2
+//	-index file
+//		write file into each translated package, declaring a variable
+//		go2goSpecializations that maps each instantiation, as in
+//		Map(int, string), to the name of the declaration created for it
+//	-prune
+//		leave out of the generated files the instantiations and the
+//		unexported functions that are not used, directly or indirectly,
+//		by the exported declarations and other code of the package
+//	-guardassertions
+//		make each type assertion to a type parameter that fails panic
+//		with a message naming the assertion and the instantiation, as in
+//		go2go: type assertion x.(T) failed in Get(int)
+//	-stamp
+//		record in the header of each generated .go file the version of
+//		go2go and the SHA-256 hash of the .go2 file it was translated
+//		from, so that tools can tell when it is out of date
+//	-keepsource ext
+//		write a copy of each translated .go2 file next to the .go file
+//		generated for it, named with the extension ext, as in
+//		foo.go2.orig for -keepsource=.go2.orig, for comparing the two
+//	-qualifynames
+//		name the package of a generic function or type, and the
+//		packages of its type arguments, by import path rather than by
+//		name in the names of its instantiations, so that packages with
+//		the same name do not produce the same names
+//	-instfile file
+//		write the instantiations made by each translated package to
+//		file, as in instantiations.gen.go, rather than to the .go files
+//		that use them; instantiations made only by tests are not moved
+//
 // Non-local imported packages will be first looked up using the GO2PATH
 // environment variable, which should point to a GOPATH-like directory.
 // For example, import "x" will first look for GO2PATHDIR/src/x,
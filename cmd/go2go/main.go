@@ -19,6 +19,24 @@ import (
 
 var gotool = filepath.Join(runtime.GOROOT(), "bin", "go")
 
+var (
+	keepLineDirectives = flag.Bool("keeplinedirectives", false, "honor //line directives found in .go2 files")
+	tabWidth           = flag.Int("tabwidth", 8, "tab width of generated files")
+	useTabs            = flag.Bool("tabs", true, "indent generated files with tabs")
+	typeArgComments    = flag.Bool("typeargcomments", false, "annotate calls of instantiated functions with their type arguments")
+	validate           = flag.Bool("validate", false, "type check generated files and report errors as translation failures")
+	splitDecls         = flag.Int("splitdecls", 0, "if positive, write at most this many instantiated declarations to each generated file")
+	splitBytes         = flag.Int("splitbytes", 0, "if positive, move instantiated declarations to additional files to keep generated files at about this many bytes")
+	generateCommand    = flag.String("generate", "", "if not empty, add a //go:generate directive running this command on the .go2 file to each generated file")
+	indexFile          = flag.String("index", "", "if not empty, write a file with this name mapping instantiations to their names into each translated package")
+	prune              = flag.Bool("prune", false, "omit instantiations and unexported functions that the package does not use")
+	guardAssertions    = flag.Bool("guardassertions", false, "report the instantiation in the panic of a failed type assertion to a type parameter")
+	stamp              = flag.Bool("stamp", false, "record the go2go version and the hash of the source file in each generated file")
+	sourceCopyExt      = flag.String("keepsource", "", "if not empty, write a copy of each translated file with this extension next to the generated file")
+	qualifyNames       = flag.Bool("qualifynames", false, "qualify the packages and type arguments in the names of instantiations by import path")
+	instFile           = flag.String("instfile", "", "if not empty, write the instantiations of each translated package to a single file with this name")
+)
+
 var cmds = map[string]bool{
 	"build":     true,
 	"run":       true,
@@ -27,6 +45,7 @@ var cmds = map[string]bool{
 }
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
 
 	args := flag.Args()
@@ -45,6 +64,23 @@ func main() {
 	defer os.RemoveAll(importerTmpdir)
 
 	importer := go2go.NewImporter(importerTmpdir)
+	importer.SetOptions(go2go.Options{
+		KeepLineDirectives:       *keepLineDirectives,
+		Tabwidth:                 *tabWidth,
+		IndentWithSpaces:         !*useTabs,
+		TypeArgComments:          *typeArgComments,
+		Validate:                 *validate,
+		SplitDecls:               *splitDecls,
+		SplitBytes:               *splitBytes,
+		GenerateCommand:          *generateCommand,
+		IndexFile:                *indexFile,
+		PruneUnreachable:         *prune,
+		GuardTypeAssertions:      *guardAssertions,
+		StampSource:              *stamp,
+		SourceCopyExt:            *sourceCopyExt,
+		InstantiationsFile:       *instFile,
+		QualifyInstantiatedNames: *qualifyNames,
+	})
 
 	var rundir string
 	if args[0] == "run" {
@@ -56,6 +92,16 @@ func main() {
 			base := filepath.Base(arg)
 			f := strings.TrimSuffix(base, ".go2") + ".go"
 			nargs = append(nargs, f)
+			split, err := filepath.Glob(filepath.Join(tmpdir, strings.TrimSuffix(base, ".go2")+".gen*.go"))
+			if err != nil {
+				die(err.Error())
+			}
+			for _, s := range split {
+				nargs = append(nargs, filepath.Base(s))
+			}
+		}
+		if *instFile != "" {
+			nargs = append(nargs, *instFile)
 		}
 		args = nargs
 		rundir = tmpdir
@@ -165,7 +211,7 @@ func copyToTmpdir(files []string) string {
 
 // usage reports a usage message and exits with failure.
 func usage() {
-	fmt.Fprint(os.Stderr, `Usage: go2go <command> [arguments]
+	fmt.Fprint(os.Stderr, `Usage: go2go [flags] <command> [arguments]
 
 The commands are:
 
@@ -173,7 +219,11 @@ The commands are:
 	run        translate and run list of files
 	test       translate and test packages
 	translate  translate .go2 files into .go files
+
+The flags are:
+
 `)
+	flag.PrintDefaults()
 	os.Exit(1)
 }
 
@@ -806,6 +806,13 @@ Found:
 			p.InvalidGoFiles = append(p.InvalidGoFiles, name)
 		}
 
+		// .go2 files are translated by go2go rather than built.
+		// matchFile accepts them only so that MatchFile can
+		// select them by build constraints.
+		if ext == ".go2" {
+			continue
+		}
+
 		match, data, filename, err := ctxt.matchFile(p.Dir, name, allTags, &p.BinaryOnly)
 		if err != nil {
 			badFile(err)
@@ -1293,6 +1300,8 @@ func parseWord(data []byte) (word, rest []byte) {
 //
 // MatchFile considers the name of the file and may use ctxt.OpenFile to
 // read some or all of the file's content.
+// It also matches .go2 files that satisfy the context, although
+// ImportDir does not include them in a Package.
 func (ctxt *Context) MatchFile(dir, name string) (match bool, err error) {
 	match, _, _, err = ctxt.matchFile(dir, name, nil, nil)
 	return
@@ -1323,7 +1332,7 @@ func (ctxt *Context) matchFile(dir, name string, allTags map[string]bool, binary
 	}
 
 	switch ext {
-	case ".go", ".c", ".cc", ".cxx", ".cpp", ".m", ".s", ".h", ".hh", ".hpp", ".hxx", ".f", ".F", ".f90", ".S", ".sx", ".swig", ".swigcxx":
+	case ".go", ".go2", ".c", ".cc", ".cxx", ".cpp", ".m", ".s", ".h", ".hh", ".hpp", ".hxx", ".f", ".F", ".f90", ".S", ".sx", ".swig", ".swigcxx":
 		// tentatively okay - read to make sure
 	case ".syso":
 		// binary, no reading
@@ -1355,9 +1364,14 @@ func (ctxt *Context) matchFile(dir, name string, allTags map[string]bool, binary
 		return
 	}
 
-	// Look for +build comments to accept or reject the file.
+	// Look for //go:build or +build comments to accept or reject the file.
 	var sawBinaryOnly bool
-	if !ctxt.shouldBuild(data, allTags, &sawBinaryOnly) && !ctxt.UseAllFiles {
+	ok, err := ctxt.shouldBuild(data, allTags, &sawBinaryOnly)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", filename, err)
+		return
+	}
+	if !ok && !ctxt.UseAllFiles {
 		return
 	}
 
@@ -1407,10 +1421,17 @@ var binaryOnlyComment = []byte("//go:binary-only-package")
 //
 // marks the file as applicable only on Windows and Linux.
 //
+// A line beginning with '//go:build' holds a boolean expression of
+// build tags, combined with ||, && and ! and grouped by parentheses.
+// If there is such a line, it alone decides whether the file is used,
+// and any +build lines are ignored. For example:
+//
+//	//go:build (linux || darwin) && !cgo
+//
 // If shouldBuild finds a //go:binary-only-package comment in the file,
 // it sets *binaryOnly to true. Otherwise it does not change *binaryOnly.
 //
-func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binaryOnly *bool) bool {
+func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binaryOnly *bool) (bool, error) {
 	sawBinaryOnly := false
 
 	// Pass 1. Identify leading run of // comments and blank lines,
@@ -1438,6 +1459,7 @@ func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binary
 	// Pass 2.  Process each line in the run.
 	p = content
 	allok := true
+	sawGoBuild := false
 	for len(p) > 0 {
 		line := p
 		if i := bytes.IndexByte(line, '\n'); i >= 0 {
@@ -1452,6 +1474,21 @@ func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binary
 		if bytes.Equal(line, binaryOnlyComment) {
 			sawBinaryOnly = true
 		}
+		if expr := bytes.TrimPrefix(line, goBuildComment); len(expr) < len(line) && (len(expr) == 0 || expr[0] == ' ' || expr[0] == '\t') {
+			if sawGoBuild {
+				return false, errors.New("multiple //go:build comments")
+			}
+			sawGoBuild = true
+			ok, err := ctxt.matchExpr(string(expr), allTags)
+			if err != nil {
+				return false, fmt.Errorf("parsing //go:build line: %v", err)
+			}
+			allok = ok
+			continue
+		}
+		if sawGoBuild {
+			continue
+		}
 		line = bytes.TrimSpace(line[len(slashslash):])
 		if len(line) > 0 && line[0] == '+' {
 			// Looks like a comment +line.
@@ -1474,9 +1511,12 @@ func (ctxt *Context) shouldBuild(content []byte, allTags map[string]bool, binary
 		*binaryOnly = true
 	}
 
-	return allok
+	return allok, nil
 }
 
+// goBuildComment is the prefix of a //go:build line.
+var goBuildComment = []byte("//go:build")
+
 // saveCgo saves the information from the #cgo lines in the import "C" comment.
 // These lines set CFLAGS, CPPFLAGS, CXXFLAGS and LDFLAGS and pkg-config directives
 // that affect the way cgo's C code is built.
@@ -1766,6 +1806,111 @@ func (ctxt *Context) match(name string, allTags map[string]bool) bool {
 	return false
 }
 
+// matchExpr reports whether the //go:build expression expr is
+// satisfied by the context. It records every tag in expr in allTags,
+// if that is not nil.
+func (ctxt *Context) matchExpr(expr string, allTags map[string]bool) (bool, error) {
+	p := &exprParser{ctxt: ctxt, allTags: allTags, s: expr}
+	p.next()
+	ok := p.or()
+	if p.err == nil && p.tok != "" {
+		p.err = fmt.Errorf("unexpected %s", p.tok)
+	}
+	if p.err != nil {
+		return false, p.err
+	}
+	return ok, nil
+}
+
+// isTagChar reports whether c may appear in a build tag.
+func isTagChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.'
+}
+
+// An exprParser evaluates a //go:build expression as it parses it.
+// Every operand is evaluated, so that all tags are recorded.
+type exprParser struct {
+	ctxt    *Context
+	allTags map[string]bool
+	s       string // remaining input
+	tok     string // current token; "" at end of input
+	err     error
+}
+
+// next advances to the next token.
+func (p *exprParser) next() {
+	p.s = strings.TrimLeft(p.s, " \t")
+	if p.s == "" {
+		p.tok = ""
+		return
+	}
+	n := 1
+	switch {
+	case strings.HasPrefix(p.s, "&&"), strings.HasPrefix(p.s, "||"):
+		n = 2
+	case p.s[0] == '!' || p.s[0] == '(' || p.s[0] == ')':
+	default:
+		n = strings.IndexFunc(p.s, func(c rune) bool { return !isTagChar(c) })
+		if n < 0 {
+			n = len(p.s)
+		} else if n == 0 {
+			_, n = utf8.DecodeRuneInString(p.s)
+		}
+	}
+	p.tok, p.s = p.s[:n], p.s[n:]
+}
+
+// or parses and evaluates x || y || ...
+func (p *exprParser) or() bool {
+	ok := p.and()
+	for p.err == nil && p.tok == "||" {
+		p.next()
+		ok = p.and() || ok
+	}
+	return ok
+}
+
+// and parses and evaluates x && y && ...
+func (p *exprParser) and() bool {
+	ok := p.not()
+	for p.err == nil && p.tok == "&&" {
+		p.next()
+		ok = p.not() && ok
+	}
+	return ok
+}
+
+// not parses and evaluates !x, (x), or a tag.
+func (p *exprParser) not() bool {
+	switch tok := p.tok; tok {
+	case "!":
+		p.next()
+		return !p.not()
+	case "(":
+		p.next()
+		ok := p.or()
+		if p.err == nil && p.tok != ")" {
+			p.err = errors.New("missing )")
+		}
+		p.next()
+		return ok
+	case "", "&&", "||", ")":
+		if p.err == nil {
+			p.err = errors.New("missing operand")
+		}
+		return false
+	default:
+		if r, _ := utf8.DecodeRuneInString(tok); !isTagChar(r) {
+			if p.err == nil {
+				p.err = fmt.Errorf("invalid syntax at %s", tok)
+			}
+			return false
+		}
+		p.next()
+		return p.ctxt.match(tok, p.allTags)
+	}
+}
+
 // goodOSArchFile returns false if the name contains a $GOOS or $GOARCH
 // suffix which does not match the current system.
 // The recognized name formats are:
@@ -149,7 +149,7 @@ func TestShouldBuild(t *testing.T) {
 
 	ctx := &Context{BuildTags: []string{"tag1"}}
 	m := map[string]bool{}
-	if !ctx.shouldBuild([]byte(file1), m, nil) {
+	if ok, _ := ctx.shouldBuild([]byte(file1), m, nil); !ok {
 		t.Errorf("shouldBuild(file1) = false, want true")
 	}
 	if !reflect.DeepEqual(m, want1) {
@@ -157,7 +157,7 @@ func TestShouldBuild(t *testing.T) {
 	}
 
 	m = map[string]bool{}
-	if ctx.shouldBuild([]byte(file2), m, nil) {
+	if ok, _ := ctx.shouldBuild([]byte(file2), m, nil); ok {
 		t.Errorf("shouldBuild(file2) = true, want false")
 	}
 	if !reflect.DeepEqual(m, want2) {
@@ -166,7 +166,7 @@ func TestShouldBuild(t *testing.T) {
 
 	m = map[string]bool{}
 	ctx = &Context{BuildTags: nil}
-	if !ctx.shouldBuild([]byte(file3), m, nil) {
+	if ok, _ := ctx.shouldBuild([]byte(file3), m, nil); !ok {
 		t.Errorf("shouldBuild(file3) = false, want true")
 	}
 	if !reflect.DeepEqual(m, want3) {
@@ -174,6 +174,54 @@ func TestShouldBuild(t *testing.T) {
 	}
 }
 
+func TestShouldBuildGoBuild(t *testing.T) {
+	ctx := &Context{BuildTags: []string{"tag1", "tag2"}, GOOS: "linux", GOARCH: "amd64"}
+	for _, test := range []struct {
+		content string
+		want    bool
+		tags    []string
+	}{
+		{"//go:build tag1\n\npackage main\n", true, []string{"tag1"}},
+		{"//go:build !tag1\n\npackage main\n", false, []string{"tag1"}},
+		{"//go:build tag1 && tag3\n\npackage main\n", false, []string{"tag1", "tag3"}},
+		{"//go:build tag3 || tag2\n\npackage main\n", true, []string{"tag2", "tag3"}},
+		{"//go:build (linux || darwin) && !(386 || arm)\n\npackage main\n", true, []string{"linux", "darwin", "386", "arm"}},
+		{"//go:build windows\n// +build linux\n\npackage main\n", false, []string{"windows"}},
+		{"// +build linux\n//go:build windows\n\npackage main\n", false, []string{"linux", "windows"}},
+		{"//go:build linux\n\n//go:build tag1\npackage main\n", true, []string{"linux"}},
+	} {
+		m := map[string]bool{}
+		got, err := ctx.shouldBuild([]byte(test.content), m, nil)
+		if err != nil {
+			t.Errorf("shouldBuild(%q) failed: %v", test.content, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("shouldBuild(%q) = %v, want %v", test.content, got, test.want)
+		}
+		want := map[string]bool{}
+		for _, tag := range test.tags {
+			want[tag] = true
+		}
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("shouldBuild(%q) tags = %v, want %v", test.content, m, want)
+		}
+	}
+
+	for _, content := range []string{
+		"//go:build\n\npackage main\n",
+		"//go:build tag1 &&\n\npackage main\n",
+		"//go:build (tag1\n\npackage main\n",
+		"//go:build tag1 tag2\n\npackage main\n",
+		"//go:build tag1,tag2\n\npackage main\n",
+		"//go:build tag1\n//go:build tag2\n\npackage main\n",
+	} {
+		if _, err := ctx.shouldBuild([]byte(content), nil, nil); err == nil {
+			t.Errorf("shouldBuild(%q) succeeded unexpectedly", content)
+		}
+	}
+}
+
 func TestGoodOSArchFile(t *testing.T) {
 	ctx := &Context{BuildTags: []string{"linux"}, GOOS: "darwin"}
 	m := map[string]bool{}
@@ -211,6 +259,9 @@ var matchFileTests = []struct {
 	{ctxtP9, "foo.go", "", true},
 	{ctxtP9, "foo1.go", "// +build linux\n\npackage main\n", false},
 	{ctxtP9, "foo.badsuffix", "", false},
+	{ctxtP9, "foo.go2", "//go:build plan9\n\npackage main\n", true},
+	{ctxtP9, "foo1.go2", "//go:build !plan9\n\npackage main\n", false},
+	{ctxtP9, "foo_linux.go2", "", false},
 	{ctxtAndroid, "foo_linux.go", "", true},
 	{ctxtAndroid, "foo_android.go", "", true},
 	{ctxtAndroid, "foo_plan9.go", "", false},
@@ -562,3 +613,17 @@ func TestCgoImportsIgnored(t *testing.T) {
 		}
 	}
 }
+
+// TestImportDirGo2 checks that .go2 files are not treated as Go files.
+func TestImportDirGo2(t *testing.T) {
+	p, err := ImportDir("testdata/go2", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p.GoFiles, []string{"a.go"}) {
+		t.Errorf("GoFiles = %v, want [a.go]", p.GoFiles)
+	}
+	if len(p.InvalidGoFiles) > 0 {
+		t.Errorf("InvalidGoFiles = %v, want none", p.InvalidGoFiles)
+	}
+}
@@ -0,0 +1,3 @@
+package p
+
+var A = 1
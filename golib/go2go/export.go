@@ -0,0 +1,162 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"github.com/tdakkota/go2go/golib/types"
+)
+
+// isExportedInstantiation reports whether the instantiation of
+// the generic type obj with targs appears in the exported
+// declarations of the package declaring obj, and that package
+// is imported by other Go 2 packages. Such an instantiation may
+// be used by the importing packages, so it is given an exported
+// name, and they refer to it rather than instantiating the type
+// themselves, which would produce a different type.
+func (imp *Importer) isExportedInstantiation(obj types.Object, targs []types.Type) bool {
+	if obj == nil || !obj.Exported() || obj.Pkg() == nil {
+		return false
+	}
+	tpkg := obj.Pkg()
+	insts, ok := imp.exportedInsts[tpkg]
+	if !ok {
+		if p, _ := imp.lookupPackage(imp.importPath(tpkg)); p == tpkg {
+			insts = exportedInstantiations(tpkg)
+		}
+		imp.exportedInsts[tpkg] = insts
+	}
+	for _, inst := range insts[obj] {
+		if len(inst) != len(targs) {
+			continue
+		}
+		same := true
+		for i, targ := range inst {
+			if !types.Identical(targ, targs[i]) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
+
+// exportedInstantiations returns the instantiations of the exported
+// generic types of tpkg that appear in the exported declarations of
+// tpkg, as a map from each generic type to the lists of type
+// arguments with which it is instantiated. Only the instantiations
+// that the translation of tpkg declares are included: generic
+// declarations are skipped, as are the methods of instantiated
+// types, since they are only translated when instantiated.
+func exportedInstantiations(tpkg *types.Package) map[types.Object][][]types.Type {
+	insts := make(map[types.Object][][]types.Type)
+	seen := make(map[types.Type]bool)
+	var record func(typ types.Type) bool
+	record = func(typ types.Type) bool {
+		named, ok := typ.(*types.Named)
+		if !ok {
+			return true
+		}
+		obj := named.Obj()
+		if obj.Pkg() != tpkg || isGeneric(named) {
+			return false
+		}
+		targs := named.TArgs()
+		if len(targs) == 0 {
+			return true
+		}
+		if hasTypeParams(targs) {
+			return false
+		}
+		if orig := tpkg.Scope().Lookup(obj.Name()); orig != nil && orig.Exported() {
+			insts[orig] = append(insts[orig], targs)
+		}
+		for _, targ := range targs {
+			inspectType(targ, seen, record)
+		}
+		inspectType(named.Underlying(), seen, record)
+		return false
+	}
+
+	scope := tpkg.Scope()
+	for _, name := range scope.Names() {
+		if obj := scope.Lookup(name); obj.Exported() && !isGeneric(obj.Type()) {
+			inspectType(obj.Type(), seen, record)
+		}
+	}
+	return insts
+}
+
+// hasTypeParams reports whether any of targs is or contains
+// a type parameter.
+func hasTypeParams(targs []types.Type) bool {
+	found := false
+	seen := make(map[types.Type]bool)
+	for _, targ := range targs {
+		inspectType(targ, seen, func(typ types.Type) bool {
+			if _, ok := typ.(*types.TypeParam); ok {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+// inspectType calls f for typ and, if f returns true, for each
+// of the types that typ is made of, in depth-first order. The
+// types of a named type are its type arguments, its underlying
+// type, and the types of its methods. Types in seen are skipped,
+// and the types visited are added to it.
+func inspectType(typ types.Type, seen map[types.Type]bool, f func(types.Type) bool) {
+	if typ == nil || seen[typ] {
+		return
+	}
+	seen[typ] = true
+	if !f(typ) {
+		return
+	}
+	inspectTuple := func(tuple *types.Tuple) {
+		for i := 0; i < tuple.Len(); i++ {
+			inspectType(tuple.At(i).Type(), seen, f)
+		}
+	}
+	switch typ := typ.(type) {
+	case *types.Pointer:
+		inspectType(typ.Elem(), seen, f)
+	case *types.Array:
+		inspectType(typ.Elem(), seen, f)
+	case *types.Slice:
+		inspectType(typ.Elem(), seen, f)
+	case *types.Map:
+		inspectType(typ.Key(), seen, f)
+		inspectType(typ.Elem(), seen, f)
+	case *types.Chan:
+		inspectType(typ.Elem(), seen, f)
+	case *types.Struct:
+		for i := 0; i < typ.NumFields(); i++ {
+			inspectType(typ.Field(i).Type(), seen, f)
+		}
+	case *types.Tuple:
+		inspectTuple(typ)
+	case *types.Signature:
+		inspectTuple(typ.Params())
+		inspectTuple(typ.Results())
+	case *types.Interface:
+		for i := 0; i < typ.NumMethods(); i++ {
+			inspectType(typ.Method(i).Type(), seen, f)
+		}
+	case *types.Named:
+		for _, targ := range typ.TArgs() {
+			inspectType(targ, seen, f)
+		}
+		inspectType(typ.Underlying(), seen, f)
+		for i := 0; i < typ.NumMethods(); i++ {
+			inspectType(typ.Method(i).Type(), seen, f)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"github.com/tdakkota/go2go/golib/types"
+)
+
+// A GenericDecl describes a parameterized function or type
+// declared at package scope.
+type GenericDecl struct {
+	Obj     types.Object // *types.Func or *types.TypeName
+	TParams []TypeParam  // type parameters in declaration order
+}
+
+// A TypeParam describes a type parameter of a GenericDecl.
+type TypeParam struct {
+	Name  string
+	Bound *types.Interface // the empty interface if unconstrained
+}
+
+// GenericDecls returns the parameterized functions and types
+// declared at package scope in pkg, sorted by name.
+// The package must have been type checked.
+func GenericDecls(pkg *types.Package) []GenericDecl {
+	var r []GenericDecl
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		var tparams []*types.TypeName
+		switch obj := obj.(type) {
+		case *types.Func:
+			tparams = obj.Type().(*types.Signature).TParams()
+		case *types.TypeName:
+			if named, ok := obj.Type().(*types.Named); ok && !obj.IsAlias() {
+				tparams = named.TParams()
+			}
+		}
+		if len(tparams) == 0 {
+			continue
+		}
+		decl := GenericDecl{Obj: obj}
+		for _, tp := range tparams {
+			decl.TParams = append(decl.TParams, TypeParam{
+				Name:  tp.Name(),
+				Bound: tp.Type().(*types.TypeParam).Bound(),
+			})
+		}
+		r = append(r, decl)
+	}
+	return r
+}
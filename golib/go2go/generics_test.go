@@ -0,0 +1,83 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/parser"
+	"github.com/tdakkota/go2go/golib/token"
+	"github.com/tdakkota/go2go/golib/types"
+	"testing"
+)
+
+const genericsSrc = `
+package p
+
+contract Stringer(T, U) {
+	U String() string
+}
+
+type List(type E) struct {
+	next *List(E)
+	val  E
+}
+
+func Map(type T, U Stringer)(s []T, f func(T) U) []U { return nil }
+
+func F() {}
+
+func Any(type A any)() {}
+
+type Int int
+`
+
+// checkSource type checks src as a single file package.
+func checkSource(t *testing.T, src string) *types.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go2", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf types.Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func TestGenericDecls(t *testing.T) {
+	decls := GenericDecls(checkSource(t, genericsSrc))
+	if len(decls) != 3 {
+		t.Fatalf("got %d generic declarations, want 3: %v", len(decls), decls)
+	}
+
+	if a := decls[0]; a.Obj.Name() != "Any" || len(a.TParams) != 1 || !a.TParams[0].Bound.Empty() {
+		t.Errorf("decls[0] = %v, want func Any with empty bound", a)
+	}
+
+	list := decls[1]
+	if _, ok := list.Obj.(*types.TypeName); !ok || list.Obj.Name() != "List" {
+		t.Errorf("decls[1].Obj = %v, want type List", list.Obj)
+	}
+	if len(list.TParams) != 1 || list.TParams[0].Name != "E" || !list.TParams[0].Bound.Empty() {
+		t.Errorf("List type parameters = %v, want unconstrained E", list.TParams)
+	}
+
+	m := decls[2]
+	if _, ok := m.Obj.(*types.Func); !ok || m.Obj.Name() != "Map" {
+		t.Errorf("decls[2].Obj = %v, want func Map", m.Obj)
+	}
+	if len(m.TParams) != 2 || m.TParams[0].Name != "T" || m.TParams[1].Name != "U" {
+		t.Fatalf("Map type parameters = %v, want T, U", m.TParams)
+	}
+	if !m.TParams[0].Bound.Empty() {
+		t.Errorf("T bound = %v, want empty interface", m.TParams[0].Bound)
+	}
+	if b := m.TParams[1].Bound; b.NumMethods() != 1 || b.Method(0).Name() != "String" {
+		t.Errorf("U bound = %v, want String method", b)
+	}
+}
@@ -7,12 +7,15 @@ package go2go
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/build"
 	"github.com/tdakkota/go2go/golib/parser"
 	"github.com/tdakkota/go2go/golib/token"
 	"github.com/tdakkota/go2go/golib/types"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -22,19 +25,93 @@ import (
 // rewritePrefix is what we put at the start of each newly generated .go file.
 const rewritePrefix = "// Code generated by go2go; DO NOT EDIT.\n\n"
 
+// Version is the version of go2go recorded in generated files by
+// the StampSource option. Builds of the go2go command may set it
+// with -ldflags=-X.
+var Version = "devel"
+
 // Rewrite rewrites the contents of a single directory.
 // It looks for all files with the extension .go2, and parses
 // them as a single package. It writes out a .go file with any
 // polymorphic code rewritten into normal code.
+// If any of the files can not be translated, the error is a *MultiError
+// describing each failing file.
 func Rewrite(importer *Importer, dir string) error {
 	_, err := rewriteToPkgs(importer, "", dir)
 	return err
 }
 
+// ScanDir reports which of the Go source files in dir need to be
+// translated, so that a build system can run the translator only
+// where it is needed. It considers the files that Rewrite would
+// translate along with the .go files not generated by go2go, and
+// returns their names, sorted, in translate if they declare or use
+// generic functions, generic types or contracts, or use the
+// predeclared any or embedded fields renamed by instantiation, and
+// in plain if they do not. A file that uses a
+// generic function declared in another file needs translating too,
+// so the files are type checked, using importer for their imports.
+func ScanDir(importer *Importer, dir string) (translate, plain []string, err error) {
+	go2files, gofiles, err := go2Files(importer, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := go2files
+	for _, f := range gofiles {
+		generated, err := isGeneratedGoFile(dir, f)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !generated {
+			files = append(files, f)
+		}
+	}
+	files, err = matchingFiles(importer.buildContext(), dir, files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parseFiles(dir, files, fset)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, pkg := range pkgs {
+		names := make([]string, 0, len(pkg.Files))
+		for name := range pkg.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		asts := make([]*ast.File, len(names))
+		for i, name := range names {
+			asts[i] = pkg.Files[name]
+		}
+
+		var merr multiErr
+		conf := types.Config{
+			Importer: importer,
+			Error:    merr.add,
+		}
+		if _, err := conf.Check(pkg.Name, fset, asts, importer.info); err != nil {
+			return nil, nil, merr.byFile(dir, pkg.Name, len(files))
+		}
+		for i, name := range names {
+			if needsTranslation(asts[i], importer.info) {
+				translate = append(translate, filepath.Base(name))
+			} else {
+				plain = append(plain, filepath.Base(name))
+			}
+		}
+	}
+	sort.Strings(translate)
+	sort.Strings(plain)
+	return translate, plain, nil
+}
+
 // rewriteToPkgs rewrites the contents of a single directory,
 // and returns the types.Packages that it computes.
 func rewriteToPkgs(importer *Importer, importPath, dir string) ([]*types.Package, error) {
-	go2files, gofiles, err := go2Files(dir)
+	go2files, gofiles, err := go2Files(importer, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -43,9 +120,46 @@ func rewriteToPkgs(importer *Importer, importPath, dir string) ([]*types.Package
 		return nil, err
 	}
 
+	go2files, err = matchingFiles(importer.buildContext(), dir, go2files)
+	if err != nil {
+		return nil, err
+	}
+
 	return rewriteFilesInPath(importer, importPath, dir, go2files)
 }
 
+// matchingFiles returns the files in dir that match the build
+// context ctxt.
+func matchingFiles(ctxt *build.Context, dir string, files []string) ([]string, error) {
+	var r []string
+	for _, f := range files {
+		var match bool
+		var err error
+		if ext := filepath.Ext(f); ext == ".go2" {
+			match, err = ctxt.MatchFile(dir, f)
+		} else {
+			// MatchFile rejects extensions it does not know,
+			// so match the file as though it were a .go2 file.
+			c := *ctxt
+			c.OpenFile = func(path string) (io.ReadCloser, error) {
+				path = strings.TrimSuffix(path, ".go2") + ext
+				if ctxt.OpenFile != nil {
+					return ctxt.OpenFile(path)
+				}
+				return os.Open(path)
+			}
+			match, err = c.MatchFile(dir, strings.TrimSuffix(f, ext)+".go2")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			r = append(r, f)
+		}
+	}
+	return r, nil
+}
+
 // namedAST holds a file name and the AST parsed from that file.
 type namedAST struct {
 	name string
@@ -72,7 +186,15 @@ func rewriteFilesInPath(importer *Importer, importPath, dir string, go2files []s
 		for n, f := range pkg.Files {
 			pkgfiles = append(pkgfiles, namedAST{n, f})
 		}
+		// Put test files last, so that they are translated after
+		// the package's own files. Test files then reuse the
+		// package's instantiations, but the package never refers
+		// to an instantiation declared only in a test file.
 		sort.Slice(pkgfiles, func(i, j int) bool {
+			ti, tj := isTestFile(pkgfiles[i].name), isTestFile(pkgfiles[j].name)
+			if ti != tj {
+				return tj
+			}
 			return pkgfiles[i].name < pkgfiles[j].name
 		})
 
@@ -88,7 +210,7 @@ func rewriteFilesInPath(importer *Importer, importPath, dir string, go2files []s
 		}
 		tpkg, err := conf.Check(pkg.Name, fset, asts, importer.info)
 		if err != nil {
-			return nil, fmt.Errorf("type checking failed for %s\n%v", pkg.Name, merr)
+			return nil, merr.byFile(dir, pkg.Name, len(go2files))
 		}
 
 		if !strings.HasSuffix(pkg.Name, "_test") {
@@ -99,9 +221,85 @@ func rewriteFilesInPath(importer *Importer, importPath, dir string, go2files []s
 		tpkgs = append(tpkgs, pkgfiles)
 	}
 
+	var errs []*FileError
+	indexes := make([]map[string]string, len(tpkgs))
 	for i, tpkg := range tpkgs {
+		cache := newInstantiationCache(importer, rpkgs[i])
+		var rewritten []namedAST
 		for j, pkgfile := range tpkg {
-			if err := rewriteFile(dir, fset, importer, importPath, rpkgs[i], pkgfile.name, pkgfile.ast, j == 0); err != nil {
+			// Test files are last; index only the
+			// instantiations made by the package itself.
+			if indexes[i] == nil && isTestFile(pkgfile.name) {
+				indexes[i] = cache.index(rpkgs[i])
+			}
+			if err := rewriteAST(fset, importer, importPath, rpkgs[i], pkgfile.ast, cache, j == 0); err != nil {
+				errs = append(errs, &FileError{Filename: filepath.Base(pkgfile.name), Err: err})
+				continue
+			}
+			rewritten = append(rewritten, pkgfile)
+		}
+		if indexes[i] == nil {
+			indexes[i] = cache.index(rpkgs[i])
+		}
+		if importer.opts.PruneUnreachable {
+			files := make([]*ast.File, len(rewritten))
+			for j, pkgfile := range rewritten {
+				files[j] = pkgfile.ast
+			}
+			pruned := pruneDecls(files)
+			for k, name := range indexes[i] {
+				if pruned[name] {
+					delete(indexes[i], k)
+				}
+			}
+		}
+		if name := importer.opts.InstantiationsFile; name != "" && !strings.HasSuffix(rpkgs[i].Name(), "_test") {
+			var files []*ast.File
+			for _, pkgfile := range rewritten {
+				if !isTestFile(pkgfile.name) {
+					files = append(files, pkgfile.ast)
+				}
+			}
+			if len(files) > 0 {
+				shared, err := collectInstantiations(importer, files)
+				if err != nil {
+					return nil, err
+				}
+				if err := writeGoFile(filepath.Join(dir, name), fset, importer, shared); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, pkgfile := range rewritten {
+			if err := writeRewrittenFile(dir, fset, importer, pkgfile.name, pkgfile.ast); err != nil {
+				errs = append(errs, &FileError{Filename: filepath.Base(pkgfile.name), Err: err})
+				continue
+			}
+			if importer.opts.SourceCopyExt != "" {
+				if err := copySourceFile(dir, importer, pkgfile.name); err != nil {
+					errs = append(errs, &FileError{Filename: filepath.Base(pkgfile.name), Err: err})
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, &MultiError{Package: rpkgs[0].Name(), Files: len(go2files), Errs: errs}
+	}
+
+	if name := importer.opts.IndexFile; name != "" {
+		for i, tpkg := range rpkgs {
+			if strings.HasSuffix(tpkg.Name(), "_test") {
+				continue
+			}
+			if err := writeIndexFile(filepath.Join(dir, name), tpkg.Name(), indexes[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if importer.opts.Validate {
+		for _, pkgfiles := range tpkgs {
+			if err := validate(importer, dir, pkgfiles, len(go2files)); err != nil {
 				return nil, err
 			}
 		}
@@ -114,11 +312,33 @@ func rewriteFilesInPath(importer *Importer, importPath, dir string, go2files []s
 // It returns a modified buffer. The filename parameter is only used
 // for error messages.
 func RewriteBuffer(importer *Importer, filename string, file []byte) ([]byte, error) {
-	fset := token.NewFileSet()
-	pf, err := parser.ParseFile(fset, filename, file, 0)
+	fset, pf, err := RewriteBufferAST(importer, filename, file)
 	if err != nil {
 		return nil, err
 	}
+	var buf bytes.Buffer
+	if err := printGoFile(&buf, fset, importer, pf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RewriteBufferAST is like RewriteBuffer, but instead of printing
+// the rewritten file it returns its AST, along with the FileSet
+// holding its positions. The AST is that of the Go 1 file: the
+// generic declarations have been removed, the instantiations and
+// the imports they need have been added, and the declarations that
+// keep the imports in use are in place. The comments of functions
+// created by instantiation are not in the Comments of the file.
+func RewriteBufferAST(importer *Importer, filename string, file []byte) (*token.FileSet, *ast.File, error) {
+	fset := token.NewFileSet()
+	pf, err := parser.ParseFile(fset, filename, file, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	if importer.opts.StampSource {
+		importer.srcHashes[fset.File(pf.Package)] = sha256.Sum256(file)
+	}
 	var merr multiErr
 	conf := types.Config{
 		Importer: importer,
@@ -126,25 +346,104 @@ func RewriteBuffer(importer *Importer, filename string, file []byte) ([]byte, er
 	}
 	tpkg, err := conf.Check(pf.Name.Name, fset, []*ast.File{pf}, importer.info)
 	if err != nil {
-		return nil, fmt.Errorf("type checking failed for %s\n%v", pf.Name.Name, merr)
+		return nil, nil, fmt.Errorf("type checking failed for %s\n%v", pf.Name.Name, merr)
 	}
 	importer.addIDs(pf)
-	if err := rewriteAST(fset, importer, "", tpkg, pf, true); err != nil {
-		return nil, err
+	if err := rewriteAST(fset, importer, "", tpkg, pf, newInstantiationCache(importer, tpkg), true); err != nil {
+		return nil, nil, err
 	}
-	var buf bytes.Buffer
-	fmt.Fprintln(&buf, rewritePrefix)
-	if err := config.Fprint(&buf, fset, pf); err != nil {
-		return nil, err
+	if importer.opts.PruneUnreachable {
+		pruneDecls([]*ast.File{pf})
 	}
-	return buf.Bytes(), nil
+	return fset, pf, nil
+}
+
+// TranslateFiles translates files, which have already been parsed
+// using fset and type checked as the package tpkg, and returns the
+// Go 1 code for each file in the same order. The files must have
+// been type checked using the Info of importer. Instantiations are
+// shared by the files, so each is declared only once, in the first
+// file that uses it. The SplitDecls, SplitBytes and InstantiationsFile
+// options are ignored.
+// If any of the files can not be translated, the error is a *MultiError
+// describing each failing file.
+func TranslateFiles(files []*ast.File, fset *token.FileSet, importer *Importer, tpkg *types.Package) ([][]byte, error) {
+	for _, f := range files {
+		importer.addIDs(f)
+	}
+
+	cache := newInstantiationCache(importer, tpkg)
+	outs := make([][]byte, len(files))
+	var errs []*FileError
+	rewritten := make([]bool, len(files))
+	for i, f := range files {
+		if err := rewriteAST(fset, importer, "", tpkg, f, cache, i == 0); err != nil {
+			filename := filepath.Base(fset.Position(f.Package).Filename)
+			errs = append(errs, &FileError{Filename: filename, Err: err})
+			continue
+		}
+		rewritten[i] = true
+	}
+	if importer.opts.PruneUnreachable {
+		var pfiles []*ast.File
+		for i, f := range files {
+			if rewritten[i] {
+				pfiles = append(pfiles, f)
+			}
+		}
+		pruneDecls(pfiles)
+	}
+	for i, f := range files {
+		if !rewritten[i] {
+			continue
+		}
+		filename := filepath.Base(fset.Position(f.Package).Filename)
+		var buf bytes.Buffer
+		if err := printGoFile(&buf, fset, importer, f); err != nil {
+			errs = append(errs, &FileError{Filename: filename, Err: err})
+			continue
+		}
+		outs[i] = buf.Bytes()
+	}
+	if len(errs) > 0 {
+		return nil, &MultiError{Files: len(files), Errs: errs}
+	}
+	return outs, nil
 }
 
-// go2Files returns the list of files in dir with a .go2 extension
-// and a list of files with a .go extension.
-// This returns an error if it finds any .go files that do not start
-// with rewritePrefix.
-func go2Files(dir string) (go2files []string, gofiles []string, err error) {
+// TranslateStream translates a single .go2 source file read from r,
+// and writes the resulting Go 1 code to w. Imported Go 2 packages
+// are translated in a temporary directory that is removed before
+// TranslateStream returns.
+func TranslateStream(r io.Reader, w io.Writer, opts Options) error {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	tmpdir, err := ioutil.TempDir("", "go2go")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	importer := NewImporter(tmpdir)
+	importer.SetOptions(opts)
+	out, err := RewriteBuffer(importer, "input.go2", src)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// isTestFile reports whether filename is the name of a test file.
+func isTestFile(filename string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(filename, filepath.Ext(filename)), "_test")
+}
+
+// go2Files returns the list of files in dir to translate, by default
+// those with a .go2 extension, and a list of files with a .go extension.
+func go2Files(importer *Importer, dir string) (go2files []string, gofiles []string, err error) {
 	f, err := os.Open(dir)
 	if err != nil {
 		return nil, nil, err
@@ -160,10 +459,10 @@ func go2Files(dir string) (go2files []string, gofiles []string, err error) {
 	go2files = make([]string, 0, len(files))
 	gofiles = make([]string, 0, len(files))
 	for _, f := range files {
-		switch filepath.Ext(f) {
-		case ".go2":
+		switch {
+		case importer.isSourceFile(f):
 			go2files = append(go2files, f)
-		case ".go":
+		case filepath.Ext(f) == ".go":
 			gofiles = append(gofiles, f)
 		}
 	}
@@ -190,30 +489,43 @@ func checkAndRemoveGofiles(dir string, gofiles []string) error {
 
 // checkGofile reports an error if the file does not start with rewritePrefix.
 func checkGoFile(dir, f string) error {
-	o, err := os.Open(filepath.Join(dir, f))
+	generated, err := isGeneratedGoFile(dir, f)
 	if err != nil {
 		return err
 	}
+	if !generated {
+		return fmt.Errorf("Go file %s was not created by go2go", f)
+	}
+	return nil
+}
+
+// isGeneratedGoFile reports whether the file f in dir is empty or
+// starts with rewritePrefix.
+func isGeneratedGoFile(dir, f string) (bool, error) {
+	o, err := os.Open(filepath.Join(dir, f))
+	if err != nil {
+		return false, err
+	}
 	defer o.Close()
 	var buf [100]byte
 	n, err := o.Read(buf[:])
-	if n > 0 && !strings.HasPrefix(string(buf[:n]), rewritePrefix) {
-		return fmt.Errorf("Go file %s was not created by go2go", f)
-	}
 	if err != nil && err != io.EOF {
-		return err
+		return false, err
 	}
-	return nil
+	return n == 0 || strings.HasPrefix(string(buf[:n]), rewritePrefix), nil
 }
 
 // parseFiles parses a list of .go2 files.
+// If any file fails to parse, the error is a *MultiError.
 func parseFiles(dir string, go2files []string, fset *token.FileSet) ([]*ast.Package, error) {
 	pkgs := make(map[string]*ast.Package)
+	var errs []*FileError
 	for _, go2f := range go2files {
 		filename := filepath.Join(dir, go2f)
-		pf, err := parser.ParseFile(fset, filename, nil, 0)
+		pf, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
 		if err != nil {
-			return nil, err
+			errs = append(errs, &FileError{Filename: go2f, Err: err})
+			continue
 		}
 
 		name := pf.Name.Name
@@ -228,6 +540,10 @@ func parseFiles(dir string, go2files []string, fset *token.FileSet) ([]*ast.Pack
 		pkg.Files[filename] = pf
 	}
 
+	if len(errs) > 0 {
+		return nil, &MultiError{Files: len(go2files), Errs: errs}
+	}
+
 	rpkgs := make([]*ast.Package, 0, len(pkgs))
 	for _, pkg := range pkgs {
 		rpkgs = append(rpkgs, pkg)
@@ -258,3 +574,64 @@ func (m multiErr) Error() string {
 	}
 	return sb.String()
 }
+
+// byFile returns the accumulated type checking errors for package pkg
+// in dir grouped by the file in which they occur. Errors without a
+// position are reported against dir. The files parameter is the number
+// of files that were checked.
+func (m multiErr) byFile(dir, pkg string, files int) *MultiError {
+	var names []string
+	perFile := make(map[string]multiErr)
+	for _, err := range m {
+		name := dir
+		if terr, ok := err.(types.Error); ok && terr.Pos.IsValid() {
+			name = filepath.Base(terr.Fset.Position(terr.Pos).Filename)
+		}
+		if _, ok := perFile[name]; !ok {
+			names = append(names, name)
+		}
+		perFile[name] = append(perFile[name], err)
+	}
+	r := &MultiError{Package: pkg, Files: files}
+	for _, name := range names {
+		r.Errs = append(r.Errs, &FileError{Filename: name, Err: perFile[name]})
+	}
+	return r
+}
+
+// A FileError is an error that occurred while translating a single file.
+type FileError struct {
+	Filename string // base name of the .go2 file, or the directory if no file is known
+	Err      error
+}
+
+// Error returns the file name followed by the error.
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Filename, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// A MultiError is returned when translating a directory
+// if one or more of its files could not be translated.
+type MultiError struct {
+	Package string       // name of the package, if known
+	Files   int          // number of files being translated
+	Errs    []*FileError // errors for each file that failed
+}
+
+// Error returns a summary line followed by the error for each file.
+func (m *MultiError) Error() string {
+	var sb strings.Builder
+	if m.Package != "" {
+		fmt.Fprintf(&sb, "package %s: ", m.Package)
+	}
+	fmt.Fprintf(&sb, "%d of %d files failed to translate", len(m.Errs), m.Files)
+	for _, e := range m.Errs {
+		fmt.Fprintf(&sb, "\n%v", strings.TrimSuffix(e.Error(), "\n"))
+	}
+	return sb.String()
+}
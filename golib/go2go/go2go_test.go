@@ -0,0 +1,1853 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/build"
+	"github.com/tdakkota/go2go/golib/parser"
+	"github.com/tdakkota/go2go/golib/token"
+	"github.com/tdakkota/go2go/golib/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// tempDir creates a new temporary directory. The caller removes it.
+func tempDir(tb testing.TB) string {
+	tb.Helper()
+	dir, err := ioutil.TempDir("", "go2go")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return dir
+}
+
+// writeFiles writes files, a map from file name to contents, into dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRewriteMultiError(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p; func Id(type T)(v T) T { return v }`,
+		"b.go2": `package p; var B int = "b"`,
+		"c.go2": `package p; func C() { return Id(1) }`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	err := Rewrite(NewImporter(tmpdir), dir)
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("Rewrite error = %v, want *MultiError", err)
+	}
+	if merr.Files != 3 {
+		t.Errorf("Files = %d, want 3", merr.Files)
+	}
+	if merr.Package != "p" || !strings.HasPrefix(merr.Error(), "package p: ") {
+		t.Errorf("Rewrite error = %v, want one for package p", merr)
+	}
+	var names []string
+	for _, e := range merr.Errs {
+		names = append(names, e.Filename)
+	}
+	if len(names) != 2 || names[0] != "b.go2" || names[1] != "c.go2" {
+		t.Errorf("failing files = %v, want [b.go2 c.go2]", names)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.go")); err == nil {
+		t.Errorf("a.go written despite errors")
+	}
+}
+
+func TestMultiErrByFile(t *testing.T) {
+	fset := token.NewFileSet()
+	f := fset.AddFile("/src/p/a.go2", -1, 10)
+	m := multiErr{
+		types.Error{Fset: fset, Pos: f.Pos(2), Msg: "in a"},
+		types.Error{Fset: fset, Pos: token.NoPos, Msg: "no position"},
+		errors.New("not a types.Error"),
+	}
+	r := m.byFile("/src/p", "p", 1)
+	if r.Package != "p" {
+		t.Errorf("Package = %q, want p", r.Package)
+	}
+	var names []string
+	for _, e := range r.Errs {
+		names = append(names, e.Filename)
+	}
+	if len(names) != 2 || names[0] != "a.go2" || names[1] != "/src/p" {
+		t.Errorf("failing files = %v, want [a.go2 /src/p]", names)
+	}
+}
+
+func TestRewriteMultiErrorParse(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p`,
+		"b.go2": `package p; func (`,
+		"c.go2": `package p; var`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	err := Rewrite(NewImporter(tmpdir), dir)
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("Rewrite error = %v, want *MultiError", err)
+	}
+	if len(merr.Errs) != 2 {
+		t.Fatalf("got %d file errors, want 2: %v", len(merr.Errs), merr)
+	}
+	for _, e := range merr.Errs {
+		if e.Filename != "b.go2" && e.Filename != "c.go2" {
+			t.Errorf("unexpected failing file %q", e.Filename)
+		}
+	}
+}
+
+func TestRewriteValidate(t *testing.T) {
+	files := map[string]string{
+		"a.go2": `package p; func Id(type T)(v T) T { return v }`,
+		"b.go2": `package p; var B = Id(1)`,
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, files)
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	// Simulate a translator bug that loses the instantiated function.
+	testHookRewrite = func(f *ast.File) {
+		decls := f.Decls[:0]
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); !ok || !strings.HasPrefix(fd.Name.Name, "instantiate") {
+				decls = append(decls, decl)
+			}
+		}
+		f.Decls = decls
+	}
+	defer func() { testHookRewrite = nil }()
+
+	dir = tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, files)
+	tmpdir = tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp = NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	err := Rewrite(imp, dir)
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("Rewrite error = %v, want *MultiError", err)
+	}
+	if len(merr.Errs) != 1 || merr.Errs[0].Filename != "b.go2" {
+		t.Fatalf("Rewrite error = %v, want error for b.go2", err)
+	}
+	if msg := merr.Errs[0].Error(); !strings.Contains(msg, "generated code does not type check") || !strings.Contains(msg, "undeclared name: instantiate୦୦Id୦int") {
+		t.Errorf("unexpected error: %v", msg)
+	}
+
+	// Without validation, the broken output is written out silently.
+	dir = tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, files)
+	tmpdir = tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	if err := Rewrite(NewImporter(tmpdir), dir); err != nil {
+		t.Errorf("Rewrite without validation failed: %v", err)
+	}
+}
+
+func TestTranslateStream(t *testing.T) {
+	const src = `package p
+
+type List(type T) struct{ v []T }
+
+func (l *List(T)) Push(v T) { l.v = append(l.v, v) }
+
+func Sum(type T interface{ type int, float64 })(s []T) T {
+	var r T
+	for _, v := range s {
+		r += v
+	}
+	return r
+}
+
+func F() float64 {
+	var l List(float64)
+	l.Push(1)
+	return Sum(l.v) + float64(Sum([]int{1, 2}))
+}
+`
+	var buf bytes.Buffer
+	if err := TranslateStream(strings.NewReader(src), &buf, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The output must be valid Go 1.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", buf.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("output does not parse: %v\n%s", err, buf.Bytes())
+	}
+	var conf types.Config
+	if _, err := conf.Check("p", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("output does not type check: %v\n%s", err, buf.Bytes())
+	}
+	if !strings.Contains(buf.String(), "func instantiate୦୦Sum୦int(s []int,) int") {
+		t.Errorf("output does not instantiate Sum(int):\n%s", buf.Bytes())
+	}
+
+	if err := TranslateStream(strings.NewReader("package p; var x int = \"\""), &buf, Options{}); err == nil {
+		t.Error("TranslateStream succeeded on invalid input")
+	}
+}
+
+func TestRewriteTestFiles(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+	dir := filepath.Join(go2path, "src", "example.com", "p")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+func Map(type T, U)(s []T, f func(T) U) []U {
+	r := make([]U, 0, len(s))
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func double(x int) int { return 2 * x }
+`,
+		"b.go2": `package p
+
+var Doubled = Map([]int{1}, double)
+`,
+		// Sorts before b.go2, but must be translated after it.
+		"a_test.go2": `package p
+
+var _ = Map([]int{2}, double)
+var _ = Map([]string{"x"}, func(s string) int { return len(s) })
+`,
+		"x_test.go2": `package p_test
+
+import "example.com/p"
+
+var _ = p.Map([]int{3}, func(i int) bool { return i > 0 })
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	// The package must type check without its test files,
+	// and with its internal test files.
+	check := func(names ...string) {
+		t.Helper()
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for _, name := range names {
+			f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, f)
+		}
+		var conf types.Config
+		if _, err := conf.Check("p", fset, files, nil); err != nil {
+			t.Errorf("%v: %v", names, err)
+		}
+	}
+	check("a.go", "b.go")
+	check("a.go", "b.go", "a_test.go")
+
+	// The test file reuses the package's instantiation.
+	data, err := ioutil.ReadFile(filepath.Join(dir, "a_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); strings.Contains(got, "func instantiate୦୦Map୦int୦int") || !strings.Contains(got, "func instantiate୦୦Map୦string୦int") {
+		t.Errorf("unexpected instantiations in a_test.go:\n%s", got)
+	}
+}
+
+func TestRewriteExportedInstantiation(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+	adir := filepath.Join(go2path, "src", "example.com", "a")
+	bdir := filepath.Join(go2path, "src", "example.com", "b")
+	for _, dir := range []string{adir, bdir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, adir, map[string]string{
+		"a.go2": `package a
+
+type List(type T) []T
+
+var Ints = List(int){1, 2}
+
+var strings = List(string){"x"}
+`,
+	})
+	writeFiles(t, bdir, map[string]string{
+		"b.go2": `package b
+
+import "example.com/a"
+
+var X a.List(int)
+
+var Y a.List(string)
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	if err := Rewrite(imp, bdir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	read := func(dir, name string) string {
+		t.Helper()
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	a := read(imp.translated["example.com/a"], "a.go")
+	for _, want := range []string{"type Instantiate୦୦List୦int ", "type instantiate୦୦List୦string "} {
+		if !strings.Contains(a, want) {
+			t.Errorf("a.go does not contain %q:\n%s", want, a)
+		}
+	}
+	b := read(bdir, "b.go")
+	for _, want := range []string{"var X a.Instantiate୦୦List୦int", "var Y instantiate୦a୦List୦string"} {
+		if !strings.Contains(b, want) {
+			t.Errorf("b.go does not contain %q:\n%s", want, b)
+		}
+	}
+}
+
+func TestRewriteExportedInstantiationUnqualified(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+	adir := filepath.Join(go2path, "src", "example.com", "a")
+	bdir := filepath.Join(go2path, "src", "example.com", "b")
+	cdir := filepath.Join(go2path, "src", "example.com", "c")
+	for _, dir := range []string{adir, bdir, cdir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, adir, map[string]string{
+		"a.go2": `package a
+
+type Box(type T) struct{ V T }
+
+var Default Box(int)
+
+func Make(type T)(v T) Box(T) { return Box(T){v} }
+`,
+	})
+	// Box(int) is named without qualification in the copy of Make.
+	writeFiles(t, bdir, map[string]string{
+		"b.go2": `package b
+
+import "example.com/a"
+
+var X = a.Make(1)
+`,
+	})
+	// Box(int) is named without qualification through a dot import.
+	writeFiles(t, cdir, map[string]string{
+		"c.go2": `package c
+
+import . "example.com/a"
+
+var X Box(int)
+`,
+	})
+
+	for _, test := range []struct {
+		dir, file, want string
+	}{
+		{bdir, "b.go", "a.Instantiate୦୦Box୦int{v}"},
+		{cdir, "c.go", "var X a.Instantiate୦୦Box୦int"},
+	} {
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		imp := NewImporter(tmpdir)
+		imp.SetOptions(Options{Validate: true})
+		if err := Rewrite(imp, test.dir); err != nil {
+			t.Errorf("Rewrite %s failed: %v", test.dir, err)
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(test.dir, test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), test.want) {
+			t.Errorf("%s does not contain %q:\n%s", test.file, test.want, data)
+		}
+	}
+}
+
+func TestRewriteExportedInstantiationGeneric(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+	adir := filepath.Join(go2path, "src", "example.com", "a")
+	bdir := filepath.Join(go2path, "src", "example.com", "b")
+	for _, dir := range []string{adir, bdir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// List(int) appears only in generic declarations,
+	// so package a does not declare it.
+	writeFiles(t, adir, map[string]string{
+		"a.go2": `package a
+
+type List(type T) []T
+
+func F(type T)(x T) List(int) { return nil }
+
+type Pair(type T) struct{ L List(int) }
+
+func (p Pair(T)) Ints() List(int) { return p.L }
+`,
+	})
+	writeFiles(t, bdir, map[string]string{
+		"b.go2": `package b
+
+import "example.com/a"
+
+var X = a.F(1)
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	if err := Rewrite(imp, bdir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(bdir, "b.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); strings.Contains(got, "Instantiate୦୦List୦int") || !strings.Contains(got, "instantiate୦a୦List୦int") {
+		t.Errorf("b.go does not instantiate List(int) itself:\n%s", got)
+	}
+}
+
+func TestRewriteDotImport(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+	adir := filepath.Join(go2path, "src", "example.com", "a")
+	bdir := filepath.Join(go2path, "src", "example.com", "b")
+	for _, dir := range []string{adir, bdir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, adir, map[string]string{
+		"a.go2": `package a
+
+type List(type T) []T
+
+func Map(type T, U)(l List(T), f func(T) U) List(U) {
+	var r List(U)
+	for _, v := range l {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func Double(type T interface{ type int })(x T) T { return 2 * x }
+
+func Plain() int { return 1 }
+
+func Call(type T)(x T) int { return Plain() }
+`,
+	})
+	writeFiles(t, bdir, map[string]string{
+		"b.go2": `package b
+
+import . "example.com/a"
+
+var X = Map(List(int){1, 2}, Double(int))
+
+var Y = Plain()
+
+var Z = Call(1)
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	if err := Rewrite(imp, bdir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(bdir, "b.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := string(data)
+	for _, want := range []string{
+		`import "example.com/a"`,
+		"var X = instantiate୦a୦Map୦int୦int(instantiate୦a୦List୦int{1, 2}, instantiate୦a୦Double୦int)",
+		"var Y = a.Plain()",
+		"return a.Plain()",
+	} {
+		if !strings.Contains(b, want) {
+			t.Errorf("b.go does not contain %q:\n%s", want, b)
+		}
+	}
+	if strings.Contains(b, `. "example.com/a"`) {
+		t.Errorf("b.go still dot imports example.com/a:\n%s", b)
+	}
+}
+
+func TestRewriteQualifyInstantiatedNames(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+	dirs := make(map[string]string)
+	for _, path := range []string{"x/list", "y/list", "example.com/a", "example.com/b"} {
+		dirs[path] = filepath.Join(go2path, "src", filepath.FromSlash(path))
+		if err := os.MkdirAll(dirs[path], 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{"x/list", "y/list"} {
+		writeFiles(t, dirs[path], map[string]string{
+			"list.go2": `package list
+
+func First(type T)(l []T) T { return l[0] }
+`,
+		})
+	}
+	writeFiles(t, dirs["example.com/a"], map[string]string{
+		"a.go2": `package a
+
+type Duration int64
+`,
+	})
+	writeFiles(t, dirs["example.com/b"], map[string]string{
+		"b.go2": `package b
+
+import (
+	"example.com/a"
+	"x/list"
+	ylist "y/list"
+)
+
+type Duration int64
+
+var X = list.First([]a.Duration{1})
+var Y = list.First([]Duration{1})
+var Z = ylist.First([]a.Duration{1})
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{QualifyInstantiatedNames: true, Validate: true})
+	if err := Rewrite(imp, dirs["example.com/b"]); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dirs["example.com/b"], "b.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := string(data)
+	for _, want := range []string{
+		"var X = instantiate୦x୮dlist୦First୦example୮acom୮da୮aDuration(",
+		"var Y = instantiate୦x୮dlist୦First୦b୮aDuration(",
+		"var Z = instantiate୦y୮dlist୦First୦example୮acom୮da୮aDuration(",
+	} {
+		if !strings.Contains(b, want) {
+			t.Errorf("b.go does not contain %q:\n%s", want, b)
+		}
+	}
+
+	pkg, base, targs, ok := ParseInstantiatedName("instantiate୦y୮dlist୦First୦example୮acom୮da୮aDuration")
+	if !ok || pkg != "y/list" || base != "First" || len(targs) != 1 || targs[0] != "example.com/a.Duration" {
+		t.Errorf("ParseInstantiatedName = %q, %q, %q, %t; want \"y/list\", \"First\", [\"example.com/a.Duration\"], true", pkg, base, targs, ok)
+	}
+}
+
+func TestRewriteExtensions(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.gox": `package p
+
+func Id(type T)(x T) T { return x }
+
+var A = Id(1)
+`,
+		"a_test.gox": `package p
+
+var B = Id("b")
+`,
+		// Not translated: the extension is not mapped.
+		"b.go2": `package p
+
+var C = Id(true)
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true, Extensions: map[string]string{".gox": "_gen.go"}})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		names[i] = filepath.Base(name)
+	}
+	if want := []string{"a_gen.go", "a_gen_test.go"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("generated files %v, want %v", names, want)
+	}
+}
+
+func TestRewriteRunawayInstantiation(t *testing.T) {
+	const src = `package p
+
+func Nest(type T)(n int, x T) int {
+	if n == 0 {
+		return 0
+	}
+	return Nest(n-1, []T{x})
+}
+
+var X = Nest(3, 1)
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	_, err := RewriteBuffer(NewImporter(tmpdir), "nest.go2", []byte(src))
+	if err == nil {
+		t.Fatal("RewriteBuffer succeeded unexpectedly")
+	}
+	if want := "nest.go2:7:9: cannot instantiate Nest: instantiations nested more than 100 levels deep"; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got error %q, want prefix %q", err, want)
+	}
+}
+
+func TestRewriteNonComparableKey(t *testing.T) {
+	const src = `package p
+
+type Table(type K comparable, V interface{}) struct{ m map[K]V }
+
+var T Table([]int, string)
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	_, err := RewriteBuffer(NewImporter(tmpdir), "table.go2", []byte(src))
+	if err == nil || !strings.Contains(err.Error(), "[]int does not satisfy comparable") {
+		t.Errorf("got error %v, want []int does not satisfy comparable", err)
+	}
+}
+
+func TestRewriteStampSource(t *testing.T) {
+	const src = "package p\n\nfunc Id(type T)(x T) T { return x }\n\nvar A = Id(1)\n"
+	// The SHA-256 hash of src.
+	const hash = "eb7889204d6d84541df432391d3c01f3da41f8d66e25aceb56a6f0d368ef45ec"
+	want := rewritePrefix + "// go2go devel; source sha256:" + hash + "\n\n"
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{StampSource: true})
+	for i := 0; i < 2; i++ {
+		out, err := RewriteBuffer(imp, "p.go2", []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(out, []byte(want)) {
+			t.Errorf("output does not start with %q:\n%s", want, out)
+		}
+	}
+
+	// The hash of a translated directory is that of the file on disk.
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"p.go2": src})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	out, err := ioutil.ReadFile(filepath.Join(dir, "p.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte(want)) {
+		t.Errorf("p.go does not start with %q:\n%s", want, out)
+	}
+
+	out, err = RewriteBuffer(imp, "p.go2", []byte(src+"\nvar B = Id(2)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(out, []byte(want)) {
+		t.Errorf("changed source has the same stamp:\n%s", out)
+	}
+}
+
+func TestRewriteSourceCopyExt(t *testing.T) {
+	files := map[string]string{
+		"p.go2": `package p
+
+func Id(type T)(x T) T { return x }
+
+var A = Id(1)
+`,
+		"p_test.go2": `package p
+
+var B = Id("b")
+`,
+	}
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, files)
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true, SourceCopyExt: ".go2.orig"})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	for _, name := range []string{"p.go", "p_test.go"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+	for name, src := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name+".orig"))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(data) != src {
+			t.Errorf("%s.orig = %q, want %q", name, data, src)
+		}
+	}
+
+	// The copies are not translated again.
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("second Rewrite failed: %v", err)
+	}
+
+	tmpdir = tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp = NewImporter(tmpdir)
+	imp.SetOptions(Options{SourceCopyExt: ".orig.go"})
+	if err := Rewrite(imp, dir); err == nil || !strings.Contains(err.Error(), "ends in .go") {
+		t.Errorf("Rewrite with extension .orig.go: got error %v, want one about .go", err)
+	}
+
+	xdir := tempDir(t)
+	defer os.RemoveAll(xdir)
+	writeFiles(t, xdir, map[string]string{"p.gox": files["p.go2"]})
+	for _, test := range []struct {
+		dir  string
+		opts Options
+	}{
+		{dir, Options{SourceCopyExt: ".go2"}},
+		{xdir, Options{SourceCopyExt: ".gox", Extensions: map[string]string{".gox": ".go"}}},
+	} {
+		tmpdir = tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		imp = NewImporter(tmpdir)
+		imp.SetOptions(test.opts)
+		if err := Rewrite(imp, test.dir); err == nil || !strings.Contains(err.Error(), "names files to translate") {
+			t.Errorf("Rewrite with extension %s: got error %v, want one about files to translate", test.opts.SourceCopyExt, err)
+		}
+	}
+}
+
+func TestScanDir(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"generic.go2": `package p
+
+func Id(type T)(x T) T { return x }
+`,
+		// Calls a generic function declared in another file.
+		"caller.go2": `package p
+
+var A = Id(1)
+`,
+		"contract.go2": `package p
+
+contract Stringer(T) {
+	T String() string
+}
+`,
+		"any.go2": `package p
+
+var B any
+`,
+		"box.go2": `package p
+
+type Box(type T) struct{ T }
+
+var Boxed Box(int)
+`,
+		// Uses a field renamed when Box is instantiated.
+		"field.go2": `package p
+
+var F = Boxed.T
+`,
+		"plain.go2": `package p
+
+func Double(x int) int { return 2 * x }
+`,
+		"plain.go": `package p
+
+var C = Double(2)
+`,
+		"plain_test.go2": `package p
+
+var D = Double(3)
+`,
+		// Generated by go2go, so not considered.
+		"generated.go": rewritePrefix + "package p\n\nvar E = 1\n",
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	translate, plain, err := ScanDir(NewImporter(tmpdir), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"any.go2", "box.go2", "caller.go2", "contract.go2", "field.go2", "generic.go2"}; !reflect.DeepEqual(translate, want) {
+		t.Errorf("files to translate %v, want %v", translate, want)
+	}
+	if want := []string{"plain.go", "plain.go2", "plain_test.go2"}; !reflect.DeepEqual(plain, want) {
+		t.Errorf("plain files %v, want %v", plain, want)
+	}
+}
+
+func TestRewriteEmbeddedFieldOtherFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+type Box(type T) struct{ T }
+
+var B Box(int)
+`,
+		// Has no generics of its own, but names the embedded
+		// field that is renamed when Box is instantiated.
+		"b.go2": `package p
+
+var X = B.T
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "b.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "var X = B.int") {
+		t.Errorf("b.go does not use the renamed field:\n%s", data)
+	}
+}
+
+func TestRewriteEmbeddedTypeParam(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+type Stringer interface{ String() string }
+
+// The constraint of T embeds S, so T has the methods of Stringer.
+func Join(type S Stringer, T interface{ S; Len() int })(xs []T) string {
+	s := ""
+	for _, x := range xs {
+		s += x.String()
+	}
+	return s
+}
+
+func Wrap(type S Stringer)(x S) interface{ S } {
+	var i interface{ S } = x
+	return i
+}
+
+type Holder(type S Stringer) struct{ v S }
+
+func (h Holder(S)) Get() interface{ S } { return h.v }
+
+type word string
+
+func (w word) String() string { return string(w) }
+func (w word) Len() int       { return len(w) }
+
+var (
+	J = Join(word, word)([]word{"a", "b"})
+	W = Wrap(word)("c").String()
+	H = Holder(word){"d"}.Get().String()
+)
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"func instantiate୦୦Join୦p୮aword୦p୮aword(xs []word) string",
+		"func instantiate୦୦Wrap୦p୮aword(x word) interface{ Stringer }",
+		"var i interface{ Stringer } = x",
+		"Get() interface{ Stringer }",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("a.go does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "interface{ word }") {
+		t.Errorf("a.go embeds a type argument that is not an interface:\n%s", got)
+	}
+}
+
+func TestRewriteEmbeddedTypeParamContract(t *testing.T) {
+	const src = `package p
+
+contract Stringer(T) {
+	T String() string
+}
+
+func Wrap(type S Stringer)(x S) interface{ S } { return x }
+
+type word string
+
+func (w word) String() string { return string(w) }
+
+var W = Wrap(word)("c")
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	_, err := RewriteBuffer(NewImporter(tmpdir), "p.go2", []byte(src))
+	if err == nil || !strings.Contains(err.Error(), "cannot embed type parameter S constrained by a contract") {
+		t.Errorf("RewriteBuffer error = %v, want one about the contract", err)
+	}
+}
+
+func TestRewriteStructFieldOrder(t *testing.T) {
+	const src = `package p
+
+type List(type T) []T
+
+type Box(type T) struct{ v T }
+
+type Rec(type K, V) struct {
+	K
+	Key K
+	(List(V))
+	Count int
+	*Box(V)
+	Title, Label string
+}
+
+type Name string
+
+var R = Rec(Name, int){K: "a", Key: "b", List: List(int){1}, Count: 2}
+var N = R.K
+var L = R.List
+var B = R.Box.v
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	fset, file, err := RewriteBufferAST(imp, "p.go2", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok || ts.Name.Name != "instantiate୦୦Rec୦p୮aName୦int" {
+			return true
+		}
+		for _, f := range ts.Type.(*ast.StructType).Fields.List {
+			field := types.ExprString(f.Type)
+			if len(f.Names) > 0 {
+				var names []string
+				for _, name := range f.Names {
+					names = append(names, name.Name)
+				}
+				field = strings.Join(names, ", ") + " " + field
+			}
+			fields = append(fields, field)
+		}
+		return false
+	})
+	want := []string{
+		"Name",
+		"Key Name",
+		"instantiate୦୦List୦int",
+		"Count int",
+		"*instantiate୦୦Box୦int",
+		"Title, Label string",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("got fields %q, want %q", fields, want)
+	}
+
+	// References to the embedded fields use their new names.
+	var buf bytes.Buffer
+	if err := printGoFile(&buf, fset, imp, file); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`{Name: "a", Key: "b", instantiate୦୦List୦int: instantiate୦୦List୦int{1}, Count: 2}`,
+		"var N = R.Name",
+		"var L = R.instantiate୦୦List୦int",
+		"var B = R.instantiate୦୦Box୦int.v",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("output does not contain %q:\n%s", s, &buf)
+		}
+	}
+}
+
+func TestTranslateFiles(t *testing.T) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, src := range []struct{ name, src string }{
+		{"a.go2", `package p
+
+func Id(type T)(v T) T { return v }
+
+var A = Id(1)
+`},
+		{"b.go2", `package p
+
+var B = Id(2)
+var C = Id("c")
+`},
+	} {
+		f, err := parser.ParseFile(fset, src.name, src.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	var conf types.Config
+	tpkg, err := conf.Check("p", fset, files, imp.Info())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outs, err := TranslateFiles(files, fset, imp, tpkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != 2 {
+		t.Fatalf("got %d outputs, want 2", len(outs))
+	}
+
+	const idInt = "func instantiate୦୦Id୦int("
+	const idString = "func instantiate୦୦Id୦string("
+	a, b := string(outs[0]), string(outs[1])
+	if strings.Count(a, idInt) != 1 || strings.Contains(a, idString) {
+		t.Errorf("unexpected instantiations in first file:\n%s", a)
+	}
+	if strings.Contains(b, idInt) || strings.Count(b, idString) != 1 {
+		t.Errorf("unexpected instantiations in second file:\n%s", b)
+	}
+	if !strings.Contains(b, "var B = instantiate୦୦Id୦int(2)") {
+		t.Errorf("second file does not use shared instantiation:\n%s", b)
+	}
+
+	fset = token.NewFileSet()
+	var gofiles []*ast.File
+	for i, out := range outs {
+		f, err := parser.ParseFile(fset, fmt.Sprintf("%d.go", i), out, 0)
+		if err != nil {
+			t.Fatalf("output does not parse: %v\n%s", err, out)
+		}
+		gofiles = append(gofiles, f)
+	}
+	if _, err := new(types.Config).Check("p", fset, gofiles, nil); err != nil {
+		t.Errorf("outputs do not type check: %v", err)
+	}
+}
+
+func TestTranslateFilesTypeArgCount(t *testing.T) {
+	for _, test := range []struct {
+		src, want string
+	}{
+		{
+			"package p\n\nfunc Id(type T)(v T) T { return v }\n\nvar A = Id(int, string)(1)\n",
+			"a.go2:5:9: got 2 type arguments but Id expects 1",
+		},
+		{
+			"package p\n\nfunc Two(type T, U)(v T, u U) T { return v }\n\nvar A = Two(int)(1, 2)\n",
+			"a.go2:5:9: got 1 type argument but Two expects 2",
+		},
+		{
+			"package p\n\ntype Pair(type K, V) struct{ k K; v V }\n\nvar A Pair(int)\n",
+			"a.go2:5:7: got 1 type argument but Pair expects 2",
+		},
+		{
+			"package p\n\ntype Pair(type K, V) struct{ k K; v V }\n\nvar A Pair(int, int, int)\n",
+			"a.go2:5:7: got 3 type arguments but Pair expects 2",
+		},
+	} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "a.go2", test.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		imp := NewImporter(tmpdir)
+		// Ignore the type errors, which report the same problem.
+		conf := types.Config{Error: func(error) {}}
+		tpkg, _ := conf.Check("p", fset, []*ast.File{f}, imp.Info())
+		_, err = TranslateFiles([]*ast.File{f}, fset, imp, tpkg)
+		if err == nil || !strings.Contains(err.Error(), test.want) || strings.Contains(err.Error(), "internal error") {
+			t.Errorf("%q: got error %v, want %q", test.src, err, test.want)
+		}
+	}
+}
+
+func TestRegisterSpecialization(t *testing.T) {
+	const src = `package p
+
+func Id(type T)(v T) T { return v }
+
+type List(type T) []T
+
+var A = Id(1)
+var B = Id("b")
+var L List(int)
+var M List(bool)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go2", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	var conf types.Config
+	tpkg, err := conf.Check("p", fset, []*ast.File{f}, imp.Info())
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp.RegisterSpecialization(Specialization{
+		Name:  "prebuilt.IdInt",
+		Orig:  tpkg.Scope().Lookup("Id"),
+		TArgs: []types.Type{types.Typ[types.Int]},
+	})
+	imp.RegisterSpecialization(Specialization{
+		Name:  "IntList",
+		Orig:  tpkg.Scope().Lookup("List"),
+		TArgs: []types.Type{types.Typ[types.Int]},
+	})
+	outs, err := TranslateFiles([]*ast.File{f}, fset, imp, tpkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(outs[0])
+	for _, want := range []string{
+		"var A = prebuilt.IdInt(1)",
+		"var B = instantiate୦୦Id୦string(\"b\")",
+		"var L IntList",
+		"var M instantiate୦୦List୦bool",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	for _, reject := range []string{"instantiate୦୦Id୦int", "instantiate୦୦List୦int"} {
+		if strings.Contains(got, reject) {
+			t.Errorf("output unexpectedly contains %q:\n%s", reject, got)
+		}
+	}
+	if stats := imp.Stats(); stats.FuncHits != 1 || stats.TypeHits != 1 {
+		t.Errorf("got %+v, want one function hit and one type hit", stats)
+	}
+}
+
+func TestRewriteBufferAST(t *testing.T) {
+	const src = `package p
+
+type List(type T) []T
+
+func Map(type T, U)(l List(T), f func(T) U) List(U) {
+	var r List(U)
+	for _, v := range l {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+var L = Map(List(int){1}, func(i int) string { return "" })
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	fset, file, err := RewriteBufferAST(imp, "p.go2", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var funcs []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			funcs = append(funcs, n.Name.Name)
+		case *ast.FuncType:
+			if n.TParams != nil {
+				t.Errorf("%s: function type has type parameters", fset.Position(n.Pos()))
+			}
+		case *ast.TypeSpec:
+			if n.TParams != nil {
+				t.Errorf("%s: type %s has type parameters", fset.Position(n.Pos()), n.Name.Name)
+			}
+		}
+		return true
+	})
+	if want := []string{"instantiate୦୦Map୦int୦string"}; !reflect.DeepEqual(funcs, want) {
+		t.Errorf("got functions %v, want %v", funcs, want)
+	}
+}
+
+func TestRewriteIndexFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+func Map(type T, U)(s []T, f func(T) U) []U { return nil }
+
+type Box(type T) struct{ v T }
+
+var _ = Map([]int{1}, func(i int) string { return "" })
+var _ Box(*Box(int))
+`,
+		"b.go2": `package p
+
+var _ = Map([]string{"s"}, func(s string) int { return len(s) })
+`,
+		"a_test.go2": `package p
+
+var _ = Map([]bool{true}, func(b bool) bool { return b })
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{IndexFile: "specializations_gen.go"})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	// The index file must compile along with the package.
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var index *ast.File
+	for _, name := range []string{"a.go", "b.go", "specializations_gen.go"} {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+		index = f
+	}
+	if _, err := new(types.Config).Check("p", fset, files, nil); err != nil {
+		t.Fatalf("package with index file does not type check: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, elt := range index.Decls[0].(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values[0].(*ast.CompositeLit).Elts {
+		kv := elt.(*ast.KeyValueExpr)
+		k, _ := strconv.Unquote(kv.Key.(*ast.BasicLit).Value)
+		v, _ := strconv.Unquote(kv.Value.(*ast.BasicLit).Value)
+		got[k] = v
+	}
+	want := map[string]string{
+		"Map(int, string)": "instantiate୦୦Map୦int୦string",
+		"Map(string, int)": "instantiate୦୦Map୦string୦int",
+		"Box(int)":         "instantiate୦୦Box୦int",
+		"Box(*Box(int))":   "instantiate୦୦Box୦୮1p୮aBox୮8int୮9",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("index = %v, want %v", got, want)
+	}
+}
+
+func TestRewriteBuildConstraints(t *testing.T) {
+	files := map[string]string{
+		"a.go2": `package p
+
+func Id(type T)(v T) T { return v }
+`,
+		"b.go2": `//go:build linux && !purego
+
+package p
+
+var B = Id("linux")
+`,
+		"c.go2": `//go:build !linux || purego
+
+package p
+
+var B = Id(1)
+`,
+		"d_windows.go2": `package p
+
+var D = Id(1.0)
+`,
+	}
+
+	for _, test := range []struct {
+		ctxt build.Context
+		want []string
+	}{
+		{build.Context{GOOS: "linux", GOARCH: "amd64"}, []string{"a.go", "b.go"}},
+		{build.Context{GOOS: "linux", GOARCH: "amd64", BuildTags: []string{"purego"}}, []string{"a.go", "c.go"}},
+		{build.Context{GOOS: "windows", GOARCH: "amd64"}, []string{"a.go", "c.go", "d_windows.go"}},
+	} {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		writeFiles(t, dir, files)
+		ctxt := test.ctxt
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		imp := NewImporter(tmpdir)
+		imp.SetOptions(Options{BuildContext: &ctxt})
+		if err := Rewrite(imp, dir); err != nil {
+			t.Fatalf("%s/%s %v: Rewrite failed: %v", ctxt.GOOS, ctxt.GOARCH, ctxt.BuildTags, err)
+		}
+		got, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range got {
+			got[i] = filepath.Base(got[i])
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s/%s %v: wrote %v, want %v", ctxt.GOOS, ctxt.GOARCH, ctxt.BuildTags, got, test.want)
+		}
+	}
+}
+
+func TestRewritePruneUnreachable(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+func Map(type T, U)(s []T, f func(T) U) []U {
+	var r []U
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func Keys(type K comparable)(m map[K]bool) []K {
+	var r []K
+	for k := range m {
+		r = append(r, k)
+	}
+	return r
+}
+
+func Lengths(s []string) []int {
+	return Map(s, func(s string) int { return len(s) })
+}
+
+func unused() []bool {
+	return Map([]int{}, func(int) bool { return true })
+}
+
+func helper() []int { return Keys(map[int]bool{}) }
+`,
+		"a_test.go2": `package p
+
+var _ = helper()
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{PruneUnreachable: true, IndexFile: "index.go", Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	out, err := ioutil.ReadFile(filepath.Join(dir, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		"func Lengths(",
+		"func instantiate୦୦Map୦string୦int(",
+		"func helper(",
+		"func instantiate୦୦Keys୦int(",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	for _, reject := range []string{"func unused(", "instantiate୦୦Map୦int୦bool"} {
+		if strings.Contains(got, reject) {
+			t.Errorf("output unexpectedly contains %q:\n%s", reject, got)
+		}
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(dir, "index.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(index), "Map(int, bool)") || !strings.Contains(string(index), "Map(string, int)") {
+		t.Errorf("unexpected index:\n%s", index)
+	}
+}
+
+func TestRewriteInstantiationsFile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+import "unsafe"
+
+func Size(type T)(x T) uintptr { return unsafe.Sizeof(x) }
+
+type Box(type T) struct{ v T }
+
+func (b *Box(T)) Get() T { return b.v }
+
+var _ = Size(1)
+`,
+		"b.go2": `package p
+
+var (
+	_ = Size(1)
+	_ = Size("")
+	_ = (&Box(int){}).Get()
+)
+`,
+		"a_test.go2": `package p
+
+var _ = Size(1)
+var _ = Size(true)
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{InstantiationsFile: "instantiations.gen.go", Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	want := map[string]int{
+		// Size(int), Size(string), Box(int), Box(int).Get
+		"instantiations.gen.go": 4,
+		"a.go":                  0,
+		"b.go":                  0,
+		// Size(bool)
+		"a_test.go": 1,
+	}
+	for name, n := range want {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), name, data, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := 0
+		for _, decl := range f.Decls {
+			if isInstantiatedDecl(decl) {
+				got++
+			}
+		}
+		if got != n {
+			t.Errorf("%s: got %d instantiated declarations, want %d:\n%s", name, got, n, data)
+		}
+		if name == "b.go" && !strings.Contains(string(data), "instantiate୦୦Size୦int(1)") {
+			t.Errorf("b.go does not refer to instantiate୦୦Size୦int:\n%s", data)
+		}
+	}
+}
+
+func TestRewriteSplitBytes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+import "unsafe"
+
+func Size(type T)(x T) uintptr { return unsafe.Sizeof(x) }
+
+type Box(type T) struct{ v T }
+
+func (b *Box(T)) Get() T { return b.v }
+
+var (
+	_ = Size(1)
+	_ = Size("")
+	_ = Size(1.0)
+	_ = Size(true)
+	_ = Size('x')
+	_ = Size(uint8(0))
+	_ = (&Box(int){}).Get()
+	_ = (&Box(string){}).Get()
+)
+`,
+	})
+
+	const max = 600
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{SplitBytes: max, Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	names := []string{"a.go"}
+	for n := 1; ; n++ {
+		name := fmt.Sprintf("a.gen%d.go", n)
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			break
+		}
+		names = append(names, name)
+	}
+	if len(names) < 3 {
+		t.Fatalf("got files %v, want a.go and at least two more", names)
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	total := 0
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(fset, name, data, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, decl := range f.Decls {
+			if isInstantiatedDecl(decl) {
+				n++
+			}
+		}
+		if name != "a.go" && n == 0 {
+			t.Errorf("%s: no instantiated declarations", name)
+		}
+		if n > 1 && len(data) > max {
+			t.Errorf("%s: %d bytes, want at most %d:\n%s", name, len(data), max, data)
+		}
+		total += n
+		files = append(files, f)
+	}
+	// Six instantiations of Size, two of Box, and their Get methods.
+	if total != 10 {
+		t.Errorf("got %d instantiated declarations, want 10", total)
+	}
+
+	conf := types.Config{Importer: imp}
+	if _, err := conf.Check("p", fset, files, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRewriteSplitBytesComments(t *testing.T) {
+	// The comments alone are larger than the limit, so a.go
+	// keeps none of the instantiated declarations.
+	comment := strings.Repeat("// This comment takes up room in the generated file.\n", 30)
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+` + comment + `
+func Id(type T)(x T) T { return x }
+
+` + comment + `
+var (
+	_ = Id(1)
+	_ = Id("")
+	_ = Id(1.0)
+	_ = Id(true)
+)
+`,
+	})
+
+	const max = 900
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{SplitBytes: max, Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	total := 0
+	for _, name := range []string{"a.go", "a.gen1.go"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), name, data, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, decl := range f.Decls {
+			if isInstantiatedDecl(decl) {
+				n++
+			}
+		}
+		switch {
+		case name == "a.go" && n > 0:
+			t.Errorf("a.go: %d bytes with %d instantiated declarations, want none", len(data), n)
+		case name != "a.go" && len(data) > max:
+			t.Errorf("%s: %d bytes, want at most %d:\n%s", name, len(data), max, data)
+		}
+		total += n
+	}
+	if total != 4 {
+		t.Errorf("got %d instantiated declarations, want 4", total)
+	}
+}
+
+func TestRewriteSplitImportName(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+	libdir := filepath.Join(go2path, "src", "example.com", "go-lib")
+	bdir := filepath.Join(go2path, "src", "example.com", "b")
+	for _, dir := range []string{libdir, bdir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The package name differs from the last element of its path.
+	writeFiles(t, libdir, map[string]string{
+		"lib.go2": `package lib
+
+type Pair(type T) struct{ A, B T }
+`,
+	})
+	writeFiles(t, bdir, map[string]string{
+		"b.go2": `package b
+
+import "example.com/go-lib"
+
+func Id(type T)(x T) T { return x }
+
+var (
+	_ = Id(1)
+	_ = Id("")
+	_ lib.Pair(int)
+)
+`,
+	})
+
+	for _, test := range []struct {
+		name string
+		opts Options
+	}{
+		{"SplitDecls", Options{SplitDecls: 1, Validate: true}},
+		{"InstantiationsFile", Options{InstantiationsFile: "instantiations.gen.go", Validate: true}},
+	} {
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		imp := NewImporter(tmpdir)
+		imp.SetOptions(test.opts)
+		if err := Rewrite(imp, bdir); err != nil {
+			t.Errorf("Rewrite with %s failed: %v", test.name, err)
+		}
+	}
+}
+
+func TestRewriteSplitDecls(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"a.go2": `package p
+
+import "unsafe"
+
+func Size(type T)(x T) uintptr { return unsafe.Sizeof(x) }
+
+type Box(type T) struct{ v T }
+
+func (b *Box(T)) Get() T { return b.v }
+
+var (
+	_ = Size(1)
+	_ = Size("")
+	_ = Size(1.0)
+	_ = (&Box(int){}).Get()
+)
+`,
+		"a_test.go2": `package p
+
+var _ = Size(true)
+var _ = Size('x')
+var _ = Size(uint8(0))
+`,
+	})
+
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{SplitDecls: 2, Validate: true})
+	if err := Rewrite(imp, dir); err != nil {
+		t.Fatalf("Rewrite failed: %v", err)
+	}
+
+	want := map[string]int{
+		// Size(int), Size(string)
+		"a.go": 2,
+		// Size(float64), Box(int)
+		"a.gen1.go": 2,
+		// Box(int).Get
+		"a.gen2.go": 1,
+		// Size(bool), Size(rune)
+		"a_test.go": 2,
+		// Size(uint8)
+		"a.gen1_test.go": 1,
+	}
+	fset := token.NewFileSet()
+	var files, testFiles []*ast.File
+	for name, n := range want {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name.Name != "p" {
+			t.Errorf("%s: package %s, want p", name, f.Name.Name)
+		}
+		got := 0
+		for _, decl := range f.Decls {
+			if isInstantiatedDecl(decl) {
+				got++
+			}
+		}
+		if got != n {
+			t.Errorf("%s: got %d instantiated declarations, want %d", name, got, n)
+		}
+		if strings.HasSuffix(name, "_test.go") {
+			testFiles = append(testFiles, f)
+		} else {
+			files = append(files, f)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.gen3.go")); err == nil {
+		t.Error("a.gen3.go unexpectedly written")
+	}
+
+	// The package type checks with and without its test files.
+	for _, fs := range [][]*ast.File{files, append(files, testFiles...)} {
+		conf := types.Config{Importer: imp}
+		if _, err := conf.Check("p", fset, fs, nil); err != nil {
+			t.Errorf("%d files: %v", len(fs), err)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package go2go
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"github.com/tdakkota/go2go/golib/ast"
 	"github.com/tdakkota/go2go/golib/build"
@@ -49,6 +50,36 @@ type Importer struct {
 
 	// Map from Object to AST type definition for parameterized types.
 	idToTypeSpec map[types.Object]*ast.TypeSpec
+
+	// Map from AST function declaration to the comments of the
+	// file in which it appears, for parameterized functions and
+	// for the functions created by instantiating them.
+	funcComments map[*ast.FuncDecl][]*ast.CommentGroup
+
+	// Map from each file translated by rewriteAST to the names
+	// under which it refers to the packages it imports, by path.
+	importNames map[*ast.File]map[string]string
+
+	// Map from package to the instantiations of its exported
+	// generic types that appear in its exported declarations,
+	// filled in as needed by isExportedInstantiation. The map
+	// is nil for packages not imported by other Go 2 packages.
+	exportedInsts map[*types.Package]map[types.Object][][]types.Type
+
+	// Instantiations declared outside the code being translated,
+	// registered by RegisterSpecialization.
+	specializations []Specialization
+
+	// SHA-256 hashes of the sources of files translated from
+	// buffers, for the StampSource option. The hashes of other
+	// files are computed from the files on disk.
+	srcHashes map[*token.File][sha256.Size]byte
+
+	// Options controlling the translation.
+	opts Options
+
+	// Counts of instantiations reused and created.
+	stats Stats
 }
 
 var _ types.ImporterFrom = &Importer{}
@@ -70,9 +101,60 @@ func NewImporter(tmpdir string) *Importer {
 		imports:      make(map[string][]string),
 		idToFunc:     make(map[types.Object]*ast.FuncDecl),
 		idToTypeSpec: make(map[types.Object]*ast.TypeSpec),
+		funcComments: make(map[*ast.FuncDecl][]*ast.CommentGroup),
+		importNames:  make(map[*ast.File]map[string]string),
+
+		exportedInsts: make(map[*types.Package]map[types.Object][][]types.Type),
+		srcHashes:     make(map[*token.File][sha256.Size]byte),
 	}
 }
 
+// Info returns the type information recorded for all the packages
+// type checked by imp. Files passed to TranslateFiles must be
+// type checked using it.
+func (imp *Importer) Info() *types.Info {
+	return imp.info
+}
+
+// Stats counts the instantiations of generic functions and types
+// found while translating. A hit is an instantiation that reuses a
+// declaration created earlier; a miss creates a new declaration.
+type Stats struct {
+	FuncHits, FuncMisses int
+	TypeHits, TypeMisses int
+}
+
+// Stats returns the counts of instantiations found by all the
+// translations done using imp so far.
+func (imp *Importer) Stats() Stats {
+	return imp.stats
+}
+
+// A Specialization is an instantiation of a generic function or type
+// that is declared outside the code being translated, for example by
+// an earlier translation of the package that declares the generic.
+type Specialization struct {
+	// Name is the name of the declaration of the instantiation.
+	// It may be qualified with a package name, as in
+	// pkg.Instantiate୦୦List୦int, if the translated files
+	// import that package.
+	Name string
+
+	// Orig is the generic function or type, a *types.Func or
+	// a *types.TypeName.
+	Orig types.Object
+
+	// TArgs are the type arguments of the instantiation.
+	TArgs []types.Type
+}
+
+// RegisterSpecialization records spec, so that translations done
+// using imp refer to spec.Name for the instantiation of spec.Orig
+// with spec.TArgs rather than declaring the instantiation anew.
+func (imp *Importer) RegisterSpecialization(spec Specialization) {
+	imp.specializations = append(imp.specializations, spec)
+}
+
 // defaultImporter is the default Go 1 Importer.
 var defaultImporter = importer.Default().(types.ImporterFrom)
 
@@ -123,11 +205,14 @@ func (imp *Importer) ImportFrom(importPath, dir string, mode types.ImportMode) (
 	}
 	var gofiles, go2files []string
 	for _, name := range names {
-		switch filepath.Ext(name) {
-		case ".go":
+		switch {
+		case imp.isSourceFile(name):
+			// Test files are not part of the imported package.
+			if !isTestFile(name) {
+				go2files = append(go2files, name)
+			}
+		case filepath.Ext(name) == ".go":
 			gofiles = append(gofiles, name)
-		case ".go2":
-			go2files = append(go2files, name)
 		}
 	}
 
@@ -328,6 +413,7 @@ func (imp *Importer) collectImports(asts []*ast.File) []string {
 
 // addIDs finds IDs for generic functions and types and adds them to a map.
 func (imp *Importer) addIDs(f *ast.File) {
+	comments := filterLineDirectives(f.Comments)
 	for _, decl := range f.Decls {
 		switch decl := decl.(type) {
 		case *ast.FuncDecl:
@@ -337,6 +423,9 @@ func (imp *Importer) addIDs(f *ast.File) {
 					panic(fmt.Sprintf("no types.Object for %q", decl.Name.Name))
 				}
 				imp.idToFunc[obj] = decl
+				if len(comments) > 0 {
+					imp.funcComments[decl] = comments
+				}
 			}
 		case *ast.GenDecl:
 			if decl.Tok == token.TYPE {
@@ -359,6 +448,17 @@ func (imp *Importer) lookupPackage(path string) (*types.Package, bool) {
 	return pkg, ok
 }
 
+// importPath returns the import path of pkg. Go 2 packages are
+// type checked with their name as their path, so look them up.
+func (imp *Importer) importPath(pkg *types.Package) string {
+	for path, p := range imp.packages {
+		if p == pkg {
+			return path
+		}
+	}
+	return pkg.Path()
+}
+
 // lookupFunc looks up a function by Object.
 func (imp *Importer) lookupFunc(obj types.Object) (*ast.FuncDecl, bool) {
 	decl, ok := imp.idToFunc[obj]
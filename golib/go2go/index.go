@@ -0,0 +1,93 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tdakkota/go2go/golib/format"
+	"github.com/tdakkota/go2go/golib/types"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indexVar is the name of the variable declared in an index file.
+const indexVar = "go2goSpecializations"
+
+// index returns the instantiations recorded in cache for the
+// package tpkg, as a map from the generic name and its type
+// arguments, as in Map(int, string), to the name declared for
+// the instantiation.
+func (cache *instantiationCache) index(tpkg *types.Package) map[string]string {
+	qual := types.RelativeTo(tpkg)
+	key := func(name string, targs []types.Type) string {
+		var sb strings.Builder
+		sb.WriteString(name)
+		sb.WriteByte('(')
+		for i, targ := range targs {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(types.TypeString(targ, qual))
+		}
+		sb.WriteByte(')')
+		return sb.String()
+	}
+
+	index := make(map[string]string)
+	for name, insts := range cache.instantiations {
+		for _, inst := range insts {
+			if inst.external {
+				continue
+			}
+			index[key(name, inst.types)] = inst.decl.Name
+		}
+	}
+	for typ, insts := range cache.typeInstantiations {
+		for _, inst := range insts {
+			// Types instantiated only as part of another
+			// type have no declaration of their own, and
+			// registered specializations are declared elsewhere.
+			if inst.decl == nil || inst.external {
+				continue
+			}
+			obj := typ.(*types.Named).Obj()
+			name := obj.Name()
+			if obj.Pkg() != tpkg {
+				name = obj.Pkg().Path() + "." + name
+			}
+			index[key(name, inst.types)] = inst.decl.Name
+		}
+	}
+	return index
+}
+
+// writeIndexFile writes the file named filename, declaring the
+// package pkg and a variable that holds index.
+func writeIndexFile(filename, pkg string, index map[string]string) error {
+	keys := make([]string, 0, len(index))
+	for k := range index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%spackage %s\n\n", rewritePrefix, pkg)
+	fmt.Fprintf(&buf, "// %s maps each instantiation of a generic function or type\n", indexVar)
+	fmt.Fprintf(&buf, "// to the name of the declaration created for it.\n")
+	fmt.Fprintf(&buf, "var %s = map[string]string{\n", indexVar)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s,\n", strconv.Quote(k), strconv.Quote(index[k]))
+	}
+	fmt.Fprintf(&buf, "}\n")
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, out, 0o644)
+}
@@ -5,10 +5,13 @@
 package go2go
 
 import (
+	"errors"
 	"fmt"
 	"github.com/tdakkota/go2go/golib/ast"
 	"github.com/tdakkota/go2go/golib/token"
 	"github.com/tdakkota/go2go/golib/types"
+	"strconv"
+	"strings"
 )
 
 // typeArgs holds type arguments for the function that we are instantiating.
@@ -18,14 +21,26 @@ type typeArgs struct {
 	types []types.Type // type arguments in order
 	toAST map[types.Object]ast.Expr
 	toTyp map[*types.TypeParam]types.Type
+
+	// bounds maps each type parameter to the AST of its bound,
+	// which is nil for a type parameter without one. An interface
+	// that embeds the type parameter embeds its bound instead.
+	bounds map[types.Object]ast.Expr
+
+	// expanding holds the type parameters whose bounds are being
+	// instantiated, to stop a bound that embeds its own type
+	// parameter from being expanded forever.
+	expanding map[types.Object]bool
 }
 
 // newTypeArgs returns a new typeArgs value.
 func newTypeArgs(typeTypes []types.Type) *typeArgs {
 	return &typeArgs{
-		types: typeTypes,
-		toAST: make(map[types.Object]ast.Expr),
-		toTyp: make(map[*types.TypeParam]types.Type),
+		types:     typeTypes,
+		toAST:     make(map[types.Object]ast.Expr),
+		toTyp:     make(map[*types.TypeParam]types.Type),
+		bounds:    make(map[types.Object]ast.Expr),
+		expanding: make(map[types.Object]bool),
 	}
 }
 
@@ -46,6 +61,7 @@ func typeArgsFromFields(t *translator, astTypes []ast.Expr, typeTypes []types.Ty
 				panic(fmt.Sprintf("%v is not a TypeParam", objParam))
 			}
 			ta.add(obj, objParam, astTypes[i], typeTypes[i])
+			ta.bounds[obj] = tf.Type
 			i++
 		}
 	}
@@ -53,9 +69,31 @@ func typeArgsFromFields(t *translator, astTypes []ast.Expr, typeTypes []types.Ty
 }
 
 // typeArgsFromTParams builds mappings from a list of type parameters
-// expressed as ast.Expr values.
-func typeArgsFromExprs(t *translator, astTypes []ast.Expr, typeTypes []types.Type, tparams []ast.Expr) *typeArgs {
+// expressed as ast.Expr values, those of the receiver of a method of
+// a generic type. The decl parameter holds the type parameters of
+// the generic type, which give the bounds of the receiver's type
+// parameters; it is nil if they are not known.
+func typeArgsFromExprs(t *translator, astTypes []ast.Expr, typeTypes []types.Type, tparams []ast.Expr, decl []*ast.Field) *typeArgs {
 	ta := newTypeArgs(typeTypes)
+	if len(decl) > 0 {
+		// The bounds refer to the type parameters of the type.
+		dta := typeArgsFromFields(t, astTypes, typeTypes, decl)
+		for obj, e := range dta.toAST {
+			ta.toAST[obj] = e
+		}
+		for param, typ := range dta.toTyp {
+			ta.toTyp[param] = typ
+		}
+		for obj, e := range dta.bounds {
+			ta.bounds[obj] = e
+		}
+	}
+	var bounds []ast.Expr
+	for _, f := range decl {
+		for range f.Names {
+			bounds = append(bounds, f.Type)
+		}
+	}
 	for i, ti := range tparams {
 		obj, ok := t.importer.info.Defs[ti.(*ast.Ident)]
 		if !ok {
@@ -67,10 +105,62 @@ func typeArgsFromExprs(t *translator, astTypes []ast.Expr, typeTypes []types.Typ
 			panic(fmt.Sprintf("%v is not a TypeParam", objParam))
 		}
 		ta.add(obj, objParam, astTypes[i], typeTypes[i])
+		if i < len(bounds) {
+			ta.bounds[obj] = bounds[i]
+		}
 	}
 	return ta
 }
 
+// typeParamFields returns the type parameters of the generic type
+// named in rtyp, the receiver type of one of its methods written as
+// a call such as List(T), or nil if they are not known.
+func (t *translator) typeParamFields(rtyp *ast.CallExpr) []*ast.Field {
+	id, ok := rtyp.Fun.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	spec, ok := t.importer.lookupTypeSpec(t.importer.info.ObjectOf(id))
+	if !ok || spec.TParams == nil {
+		return nil
+	}
+	return spec.TParams.List
+}
+
+// embeddedBound returns the instantiated bound of the type parameter
+// that e names, to take the place of e where it is embedded in an
+// interface, and reports whether e names a type parameter. Go 1 can
+// only embed interfaces, and the type argument need not be one.
+func (t *translator) embeddedBound(ta *typeArgs, e ast.Expr) (ast.Expr, bool) {
+	id, ok := e.(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+	obj := t.importer.info.ObjectOf(id)
+	bound, ok := ta.bounds[obj]
+	if !ok {
+		return nil, false
+	}
+	if bound != nil {
+		// A contract has no type to embed.
+		if tv, ok := t.importer.info.Types[bound]; !ok || !tv.IsType() {
+			if t.err == nil {
+				t.err = fmt.Errorf("%s: cannot embed type parameter %s constrained by a contract", t.fset.Position(id.Pos()), id.Name)
+			}
+			return nil, false
+		}
+	}
+	if bound == nil || ta.expanding[obj] {
+		// A bound that embeds its own type parameter saw
+		// the empty interface when it was type checked.
+		return &ast.InterfaceType{Interface: id.Pos(), Methods: &ast.FieldList{}}, true
+	}
+	ta.expanding[obj] = true
+	r := t.instantiateExpr(ta, bound)
+	delete(ta.expanding, obj)
+	return r, true
+}
+
 // add adds mappings for obj to ast and typ.
 func (ta *typeArgs) add(obj types.Object, objParam *types.TypeParam, ast ast.Expr, typ types.Type) {
 	ta.toAST[obj] = ast
@@ -89,6 +179,105 @@ func (ta *typeArgs) typ(param *types.TypeParam) (types.Type, bool) {
 	return t, ok
 }
 
+// An instContext is an instantiation in progress.
+type instContext struct {
+	qid   qualifiedIdent
+	types []types.Type
+}
+
+// testHookInstantiate, if not nil, is called with the name of each
+// generic function or type at the start of its instantiation.
+// Tests use it to simulate translation bugs.
+var testHookInstantiate func(name string)
+
+// pushInst records the start of the instantiation of qid with typeTypes.
+func (t *translator) pushInst(qid qualifiedIdent, typeTypes []types.Type) {
+	t.instStack = append(t.instStack, instContext{qid, typeTypes})
+	if testHookInstantiate != nil {
+		testHookInstantiate(qid.ident.Name)
+	}
+}
+
+// maxInstDepth limits the nesting of instantiations, that is, of
+// instantiations made by the declarations created for other
+// instantiations. Generic code that instantiates itself with ever
+// larger type arguments would otherwise be instantiated forever.
+const maxInstDepth = 100
+
+// instDepthError returns the error for an instantiation of qid at
+// call if it would be nested more than maxInstDepth levels deep,
+// or nil if it would not.
+func (t *translator) instDepthError(call *ast.CallExpr, qid qualifiedIdent) error {
+	if len(t.instStack) < maxInstDepth {
+		return nil
+	}
+	return fmt.Errorf("%s: cannot instantiate %s: instantiations nested more than %d levels deep (does it instantiate itself with ever larger type arguments?)", t.fset.Position(call.Pos()), qid, maxInstDepth)
+}
+
+// typeArgCountError returns the error for an instantiation of qid
+// at call with the type arguments typeList if their number does not
+// match that of the type parameters tparams, or nil if it does. The
+// type checker reports such instantiations, but TranslateFiles may
+// be given files whose type errors were ignored.
+func (t *translator) typeArgCountError(call *ast.CallExpr, qid qualifiedIdent, typeList []types.Type, tparams []*types.TypeName) error {
+	if len(typeList) == len(tparams) {
+		return nil
+	}
+	args := "type arguments"
+	if len(typeList) == 1 {
+		args = "type argument"
+	}
+	return fmt.Errorf("%s: got %d %s but %s expects %d", t.fset.Position(call.Pos()), len(typeList), args, qid, len(tparams))
+}
+
+// popInst records the end of the innermost instantiation.
+// It is not deferred, so that the stack is still intact
+// when we recover from a panic during the instantiation.
+func (t *translator) popInst() {
+	t.instStack = t.instStack[:len(t.instStack)-1]
+}
+
+// addNewDecl adds a declaration created by the current instantiation.
+func (t *translator) addNewDecl(d ast.Decl) {
+	t.newDecls = append(t.newDecls, d)
+	t.declInsts[d] = append([]instContext(nil), t.instStack...)
+}
+
+// generated reports decl, the instantiation of obj with typeTypes,
+// to the OnGenerate hook, if any.
+func (t *translator) generated(decl ast.Decl, obj types.Object, typeTypes []types.Type) {
+	if f := t.importer.opts.OnGenerate; f != nil {
+		f(decl, obj, typeTypes)
+	}
+}
+
+// panicError converts r, a value recovered from a panic during
+// translation, into an error that describes the instantiations
+// that were in progress, innermost first.
+func (t *translator) panicError(r interface{}) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "internal error: %s", strings.TrimSpace(fmt.Sprint(r)))
+	for i := len(t.instStack) - 1; i >= 0; i-- {
+		fmt.Fprintf(&sb, "\n\twhile instantiating %s", t.instString(t.instStack[i]))
+	}
+	return errors.New(sb.String())
+}
+
+// instString returns a description of the instantiation ic,
+// as in Map(int, string).
+func (t *translator) instString(ic instContext) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s(", ic.qid)
+	for j, typ := range ic.types {
+		if j > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(types.TypeString(typ, types.RelativeTo(t.tpkg)))
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
 // instantiateFunction creates a new instantiation of a function.
 func (t *translator) instantiateFunction(qid qualifiedIdent, astTypes []ast.Expr, typeTypes []types.Type) (*ast.Ident, error) {
 	name, err := t.instantiatedName(qid, typeTypes)
@@ -112,11 +301,118 @@ func (t *translator) instantiateFunction(qid qualifiedIdent, astTypes []ast.Expr
 		Type: t.instantiateExpr(ta, decl.Type).(*ast.FuncType),
 		Body: t.instantiateBlockStmt(ta, decl.Body),
 	}
-	t.newDecls = append(t.newDecls, newDecl)
+	if err := t.checkNoTypeParams(qid, newDecl); err != nil {
+		return nil, err
+	}
+	t.addNewDecl(newDecl)
+	t.importer.funcComments[newDecl] = t.importer.funcComments[decl]
+	t.generated(newDecl, t.findTypesObject(qid), typeTypes)
 
 	return instIdent, nil
 }
 
+// instantiateMethod creates a new instantiation of mdecl, the
+// declaration of method, a method with type parameters of its own.
+// The instantiation is a function whose first parameter is the
+// receiver. The receiver type arguments, if any, come first in
+// typeTypes, followed by those of the method.
+func (t *translator) instantiateMethod(qid qualifiedIdent, method *types.Func, mdecl *ast.FuncDecl, recvTypes, astTypes []ast.Expr, typeTypes []types.Type) (*ast.Ident, error) {
+	name, err := t.instantiatedName(qid, typeTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	rfield := mdecl.Recv.List[0]
+	rtyp := rfield.Type
+	if p, ok := rtyp.(*ast.StarExpr); ok {
+		rtyp = p.X
+	}
+	var ta *typeArgs
+	if c, ok := rtyp.(*ast.CallExpr); ok {
+		ta = typeArgsFromExprs(t, recvTypes, typeTypes, c.Args, t.typeParamFields(c))
+	} else {
+		ta = newTypeArgs(typeTypes)
+	}
+	mta := typeArgsFromFields(t, astTypes, typeTypes[len(recvTypes):], mdecl.Type.TParams.List)
+	for obj, e := range mta.toAST {
+		ta.toAST[obj] = e
+	}
+	for param, typ := range mta.toTyp {
+		ta.toTyp[param] = typ
+	}
+	for obj, e := range mta.bounds {
+		ta.bounds[obj] = e
+	}
+
+	names := rfield.Names
+	if len(names) == 0 {
+		names = []*ast.Ident{ast.NewIdent("_")}
+	}
+	recv := &ast.Field{
+		Doc:     rfield.Doc,
+		Names:   names,
+		Type:    t.instantiateExpr(ta, rfield.Type),
+		Comment: rfield.Comment,
+	}
+
+	ftyp := t.instantiateExpr(ta, mdecl.Type).(*ast.FuncType)
+	params := &ast.FieldList{Opening: mdecl.Recv.Opening, Closing: mdecl.Recv.Closing}
+	if ftyp.Params != nil {
+		params.Opening = ftyp.Params.Opening
+		params.Closing = ftyp.Params.Closing
+		params.List = append(params.List, ftyp.Params.List...)
+	}
+	params.List = append([]*ast.Field{recv}, params.List...)
+
+	instIdent := ast.NewIdent(name)
+	newDecl := &ast.FuncDecl{
+		Doc:  mdecl.Doc,
+		Name: instIdent,
+		Type: &ast.FuncType{
+			Func:    ftyp.Func,
+			Params:  params,
+			Results: ftyp.Results,
+		},
+		Body: t.instantiateBlockStmt(ta, mdecl.Body),
+	}
+	if err := t.checkNoTypeParams(qid, newDecl); err != nil {
+		return nil, err
+	}
+	t.addNewDecl(newDecl)
+	t.importer.funcComments[newDecl] = t.importer.funcComments[mdecl]
+	t.generated(newDecl, method, typeTypes)
+
+	return instIdent, nil
+}
+
+// checkNoTypeParams reports an error if the signature of fd, an
+// instantiation of qid, still refers to a type parameter. All type
+// parameters must have been replaced by type arguments, including
+// phantom type parameters that appear only in constraints.
+func (t *translator) checkNoTypeParams(qid qualifiedIdent, fd *ast.FuncDecl) error {
+	var err error
+	check := func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		id, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if tn, ok := t.importer.info.ObjectOf(id).(*types.TypeName); ok {
+			if _, ok := tn.Type().(*types.TypeParam); ok {
+				err = fmt.Errorf("instantiation of %s refers to type parameter %s", qid, id.Name)
+			}
+		}
+		return true
+	}
+	if fd.Recv != nil {
+		ast.Inspect(fd.Recv, check)
+	}
+	ast.Inspect(fd.Type, check)
+	return err
+}
+
 // findFuncDecl looks for the FuncDecl for qid.
 func (t *translator) findFuncDecl(qid qualifiedIdent) (*ast.FuncDecl, error) {
 	obj := t.findTypesObject(qid)
@@ -130,6 +426,37 @@ func (t *translator) findFuncDecl(qid qualifiedIdent) (*ast.FuncDecl, error) {
 	return decl, nil
 }
 
+// findMethodDecl looks for the declaration of the method name of
+// the generic type from which recv was instantiated. It returns an
+// identifier for the method, written as Type.Method, and the method.
+func (t *translator) findMethodDecl(recv *types.Named, name string) (qualifiedIdent, *types.Func, *ast.FuncDecl, error) {
+	obj := recv.Obj()
+	qid := qualifiedIdent{ident: ast.NewIdent(obj.Name() + "." + name)}
+	if obj.Pkg() != t.tpkg {
+		qid.pkg = obj.Pkg()
+	}
+	orig, ok := obj.Type().(*types.Named)
+	if len(recv.TArgs()) > 0 && obj.Pkg() != nil {
+		if o := obj.Pkg().Scope().Lookup(obj.Name()); o != nil {
+			orig, ok = o.Type().(*types.Named)
+		}
+	}
+	if ok {
+		for i := 0; i < orig.NumMethods(); i++ {
+			m := orig.Method(i)
+			if m.Name() != name {
+				continue
+			}
+			decl, ok := t.importer.lookupFunc(m)
+			if !ok {
+				return qid, nil, nil, fmt.Errorf("could not find method body for %q", qid)
+			}
+			return qid, m, decl, nil
+		}
+	}
+	return qid, nil, nil, fmt.Errorf("cannot instantiate method %s: it is not declared by %s", name, obj.Name())
+}
+
 // findTypesObject looks up the types.Object for qid.
 // It returns nil if the ID is not found.
 func (t *translator) findTypesObject(qid qualifiedIdent) types.Object {
@@ -167,7 +494,8 @@ func (t *translator) instantiateTypeDecl(qid qualifiedIdent, typ *types.Named, a
 		Tok:   token.TYPE,
 		Specs: []ast.Spec{newSpec},
 	}
-	t.newDecls = append(t.newDecls, newDecl)
+	t.addNewDecl(newDecl)
+	t.generated(newDecl, t.findTypesObject(qid), typeTypes)
 
 	instType := t.instantiateType(ta, typ.Underlying())
 
@@ -180,6 +508,12 @@ func (t *translator) instantiateTypeDecl(qid qualifiedIdent, typ *types.Named, a
 		if !ok {
 			panic(fmt.Sprintf("no AST for method %v", method))
 		}
+		if mast.Type.TParams != nil {
+			// Go 1 has no methods with type parameters.
+			// Each call is instantiated as a function by
+			// translateMethodInstantiation.
+			continue
+		}
 		rtyp := mast.Recv.List[0].Type
 		newRtype := ast.Expr(ast.NewIdent(name))
 		if p, ok := rtyp.(*ast.StarExpr); ok {
@@ -189,7 +523,7 @@ func (t *translator) instantiateTypeDecl(qid qualifiedIdent, typ *types.Named, a
 			}
 		}
 		tparams := rtyp.(*ast.CallExpr).Args
-		ta := typeArgsFromExprs(t, astTypes, typeTypes, tparams)
+		ta := typeArgsFromExprs(t, astTypes, typeTypes, tparams, spec.TParams.List)
 		newDecl := &ast.FuncDecl{
 			Doc: mast.Doc,
 			Recv: &ast.FieldList{
@@ -210,7 +544,8 @@ func (t *translator) instantiateTypeDecl(qid qualifiedIdent, typ *types.Named, a
 			Type: t.instantiateExpr(ta, mast.Type).(*ast.FuncType),
 			Body: t.instantiateBlockStmt(ta, mast.Body),
 		}
-		t.newDecls = append(t.newDecls, newDecl)
+		t.addNewDecl(newDecl)
+		t.importer.funcComments[newDecl] = t.importer.funcComments[mast]
 	}
 
 	return instIdent, instType, nil
@@ -571,6 +906,78 @@ func (t *translator) instantiateField(ta *typeArgs, f *ast.Field) *ast.Field {
 	}
 }
 
+// embeddedFieldName returns the name in the translated code of the
+// field v, with typ its type after instantiation, and reports whether
+// it differs from the name of v. A field that embeds a type parameter
+// embeds the type argument once instantiated, and a field that embeds
+// an instantiated type embeds the type declared for the instantiation;
+// either way the field takes the name of the type it embeds.
+func (t *translator) embeddedFieldName(v *types.Var, typ types.Type) (string, bool) {
+	if !v.IsField() || !v.Embedded() || !t.embedsTypeArg(v) {
+		return "", false
+	}
+	if p, ok := typ.(*types.Pointer); ok {
+		typ = p.Elem()
+	}
+	var name string
+	switch typ := typ.(type) {
+	case *types.Named:
+		obj := typ.Obj()
+		name = obj.Name()
+		if targs := typ.TArgs(); len(targs) > 0 {
+			switch {
+			case t.importer.isExportedInstantiation(obj, targs):
+				name = exportedInstantiatedName(name, targs, t.qualifier())
+			case obj.Pkg() == t.tpkg:
+				name = encodeInstantiation("", name, targs, t.qualifier())
+			default:
+				name = encodeInstantiation(t.pkgComponent(obj.Pkg()), name, targs, t.qualifier())
+			}
+		}
+	case *types.Basic:
+		name = typ.Name()
+	default:
+		return "", false
+	}
+	return name, name != v.Name()
+}
+
+// embedsTypeArg reports whether the field v embeds a type parameter
+// or an instantiated type, or is a field of an instantiated type that
+// does. A field of an instantiated type has the position of the field
+// of the generic type from which it is instantiated.
+func (t *translator) embedsTypeArg(v *types.Var) bool {
+	if t.embeddedTypeArgs == nil {
+		t.embeddedTypeArgs = embeddedTypeArgFields(t.importer.info)
+	}
+	return t.embeddedTypeArgs[v.Pos()]
+}
+
+// embeddedTypeArgFields returns the positions of the fields defined
+// in info that embed a type parameter or an instantiated type.
+func embeddedTypeArgFields(info *types.Info) map[token.Pos]bool {
+	fields := make(map[token.Pos]bool)
+	for _, obj := range info.Defs {
+		f, ok := obj.(*types.Var)
+		if !ok || !f.IsField() || !f.Embedded() {
+			continue
+		}
+		typ := f.Type()
+		if p, ok := typ.(*types.Pointer); ok {
+			typ = p.Elem()
+		}
+		switch typ := typ.(type) {
+		case *types.TypeParam:
+			fields[f.Pos()] = true
+		case *types.Named:
+			if len(typ.TArgs()) > 0 {
+				fields[f.Pos()] = true
+			}
+		}
+	}
+	return fields
+}
+
 // instantiateExpr instantiates an expression.
 func (t *translator) instantiateExpr(ta *typeArgs, e ast.Expr) ast.Expr {
 	var r ast.Expr
@@ -579,10 +986,25 @@ func (t *translator) instantiateExpr(ta *typeArgs, e ast.Expr) ast.Expr {
 		return nil
 	case *ast.Ident:
 		obj := t.importer.info.ObjectOf(e)
+		if v, ok := obj.(*types.Var); ok && v.Embedded() {
+			if tn, ok := t.importer.info.Uses[e].(*types.TypeName); ok {
+				// e is both the type of an embedded field
+				// and the name of the field it defines.
+				obj = tn
+			} else if name, ok := t.embeddedFieldName(v, t.instantiateType(ta, v.Type())); ok {
+				return &ast.Ident{NamePos: e.NamePos, Name: name}
+			}
+		}
 		if obj != nil {
 			if typ, ok := ta.ast(obj); ok {
 				return typ
 			}
+			if pn, ok := obj.(*types.PkgName); ok {
+				return t.pkgIdent(e, pn)
+			}
+			if name, ok := t.qualifiedName(obj); ok {
+				return &ast.Ident{NamePos: e.NamePos, Name: name}
+			}
 		}
 		return e
 	case *ast.Ellipsis:
@@ -631,12 +1053,16 @@ func (t *translator) instantiateExpr(ta *typeArgs, e ast.Expr) ast.Expr {
 		}
 	case *ast.SelectorExpr:
 		x := t.instantiateExpr(ta, e.X)
-		if x == e.X {
+		sel := e.Sel
+		if v, ok := t.importer.info.Uses[sel].(*types.Var); ok && v.IsField() {
+			sel = t.instantiateExpr(ta, sel).(*ast.Ident)
+		}
+		if x == e.X && sel == e.Sel {
 			return e
 		}
 		r = &ast.SelectorExpr{
 			X:   x,
-			Sel: e.Sel,
+			Sel: sel,
 		}
 	case *ast.IndexExpr:
 		x := t.instantiateExpr(ta, e.X)
@@ -650,6 +1076,18 @@ func (t *translator) instantiateExpr(ta *typeArgs, e ast.Expr) ast.Expr {
 			Index:  index,
 			Rbrack: e.Rbrack,
 		}
+	case *ast.IndexListExpr:
+		x := t.instantiateExpr(ta, e.X)
+		indices, indicesChanged := t.instantiateExprList(ta, e.Indices)
+		if x == e.X && !indicesChanged {
+			return e
+		}
+		r = &ast.IndexListExpr{
+			X:       x,
+			Lbrack:  e.Lbrack,
+			Indices: indices,
+			Rbrack:  e.Rbrack,
+		}
 	case *ast.SliceExpr:
 		x := t.instantiateExpr(ta, e.X)
 		low := t.instantiateExpr(ta, e.Low)
@@ -670,6 +1108,10 @@ func (t *translator) instantiateExpr(ta *typeArgs, e ast.Expr) ast.Expr {
 	case *ast.TypeAssertExpr:
 		x := t.instantiateExpr(ta, e.X)
 		typ := t.instantiateExpr(ta, e.Type)
+		if t.importer.opts.GuardTypeAssertions && t.isGuardedAssertion(e) {
+			r = t.guardedAssertion(e, x, typ)
+			break
+		}
 		if x == e.X && typ == e.Type {
 			return e
 		}
@@ -788,15 +1230,40 @@ func (t *translator) instantiateExpr(ta *typeArgs, e ast.Expr) ast.Expr {
 			Results: results,
 		}
 	case *ast.InterfaceType:
+		// An instantiated interface may be used as an ordinary
+		// interface type, so drop any type list, which Go 1
+		// does not permit. The type checker has already
+		// verified any uses of the interface as a constraint.
 		eMethods, eTypes := splitFieldList(e.Methods)
 		methods := t.instantiateFieldList(ta, eMethods)
-		types, typesChanged := t.instantiateExprList(ta, eTypes)
-		if methods == e.Methods && !typesChanged {
+		if eMethods != nil {
+			// An embedded type parameter is replaced by its bound.
+			for i, f := range eMethods.List {
+				if len(f.Names) > 0 {
+					continue
+				}
+				if bound, ok := t.embeddedBound(ta, f.Type); ok {
+					if methods == eMethods {
+						methods = &ast.FieldList{
+							Opening: eMethods.Opening,
+							List:    append([]*ast.Field(nil), eMethods.List...),
+							Closing: eMethods.Closing,
+						}
+					}
+					methods.List[i] = &ast.Field{
+						Doc:     f.Doc,
+						Type:    bound,
+						Comment: f.Comment,
+					}
+				}
+			}
+		}
+		if methods == eMethods && len(eTypes) == 0 {
 			return e
 		}
 		r = &ast.InterfaceType{
 			Interface:  e.Interface,
-			Methods:    mergeFieldList(methods, types),
+			Methods:    methods,
 			Incomplete: e.Incomplete,
 		}
 	case *ast.MapType:
@@ -832,6 +1299,84 @@ func (t *translator) instantiateExpr(ta *typeArgs, e ast.Expr) ast.Expr {
 	return r
 }
 
+// isGuardedAssertion reports whether the type assertion e, in the
+// generic code being instantiated, is checked by guardedAssertion:
+// its type is a type parameter and its result is a single value.
+func (t *translator) isGuardedAssertion(e *ast.TypeAssertExpr) bool {
+	if e.Type == nil {
+		return false // type switch
+	}
+	if _, ok := t.lookupType(e.Type).(*types.TypeParam); !ok {
+		return false
+	}
+	// The type of a comma-ok assertion is recorded as a tuple.
+	_, commaOk := t.importer.info.Types[e].Type.(*types.Tuple)
+	return !commaOk
+}
+
+// guardedAssertion returns the instantiation of the type assertion
+// e, with x and typ the instantiated operand and type, as a call
+// of a function literal that makes the assertion and panics with a
+// message naming it and the current instantiation if it fails:
+//
+//	func(x୦ interface{}) int {
+//		v୦, ok୦ := x୦.(int)
+//		if !ok୦ {
+//			panic("go2go: type assertion x.(T) failed in F(int)")
+//		}
+//		return v୦
+//	}(x)
+func (t *translator) guardedAssertion(e *ast.TypeAssertExpr, x, typ ast.Expr) ast.Expr {
+	xName := "x" + string(nameSep)
+	vName := "v" + string(nameSep)
+	okName := "ok" + string(nameSep)
+	msg := fmt.Sprintf("go2go: type assertion %s failed", types.ExprString(e))
+	if len(t.instStack) > 0 {
+		msg += " in " + t.instString(t.instStack[len(t.instStack)-1])
+	}
+	return &ast.CallExpr{
+		Fun: &ast.FuncLit{
+			Type: &ast.FuncType{
+				Params: &ast.FieldList{
+					List: []*ast.Field{
+						{
+							Names: []*ast.Ident{ast.NewIdent(xName)},
+							Type:  &ast.InterfaceType{Methods: &ast.FieldList{}},
+						},
+					},
+				},
+				Results: &ast.FieldList{
+					List: []*ast.Field{{Type: typ}},
+				},
+			},
+			Body: &ast.BlockStmt{
+				List: []ast.Stmt{
+					&ast.AssignStmt{
+						Lhs: []ast.Expr{ast.NewIdent(vName), ast.NewIdent(okName)},
+						Tok: token.DEFINE,
+						Rhs: []ast.Expr{&ast.TypeAssertExpr{X: ast.NewIdent(xName), Type: typ}},
+					},
+					&ast.IfStmt{
+						Cond: &ast.UnaryExpr{Op: token.NOT, X: ast.NewIdent(okName)},
+						Body: &ast.BlockStmt{
+							List: []ast.Stmt{
+								&ast.ExprStmt{
+									X: &ast.CallExpr{
+										Fun:  ast.NewIdent("panic"),
+										Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(msg)}},
+									},
+								},
+							},
+						},
+					},
+					&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent(vName)}},
+				},
+			},
+		},
+		Args: []ast.Expr{x},
+	}
+}
+
 // instantiateExprList instantiates an expression list.
 func (t *translator) instantiateExprList(ta *typeArgs, el []ast.Expr) ([]ast.Expr, bool) {
 	nel := make([]ast.Expr, len(el))
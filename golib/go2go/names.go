@@ -7,10 +7,44 @@ package go2go
 import (
 	"fmt"
 	"github.com/tdakkota/go2go/golib/types"
+	"strconv"
 	"strings"
 	"unicode"
 )
 
+// Instantiated functions and types are named
+//
+//	instantiate୦pkg୦Name୦T1୦T2...
+//
+// where pkg is the name of the package that declares the generic
+// Name (empty for the package being translated), or, with the
+// QualifyInstantiatedNames option, its import path encoded like a
+// type argument, and T1, T2, ... are
+// the type arguments. Each type argument is written as its type string,
+// with types from other packages qualified by package path, and then
+// encoded as an identifier: letters, digits, and '_' are copied, a
+// character listed in nameCodes is written as nameIntro followed by its
+// code as a single hex digit, and any other character is written as
+// nameIntro, 'u', and its code point as six hex digits. For example,
+// Map(string, []*int) becomes
+//
+//	instantiate୦୦Map୦string୦୮6୮7୮1int
+//
+// A method with type parameters of its own is instantiated as a
+// function named for Type.Method, with the '.' encoded, and the type
+// arguments of the receiver followed by those of the method:
+//
+//	instantiate୦୦List୮aMap୦int୦string
+//
+// When a package is imported by other Go 2 packages, an instantiation
+// of its exported generic type that appears in its exported
+// declarations may be used by those packages, so it is given an
+// exported name, with instantiate written as Instantiate:
+//
+//	Instantiate୦୦List୦int
+//
+// The encoding is reversible; see ParseInstantiatedName.
+
 // We use Oriya digit zero as a separator.
 // Do not use this character in your own identifiers.
 const nameSep = '୦'
@@ -19,6 +53,10 @@ const nameSep = '୦'
 // Do not use this character in your own identifiers.
 const nameIntro = '୮'
 
+// nameUnicode follows nameIntro to introduce a character
+// that has no code in nameCodes.
+const nameUnicode = 'u'
+
 var nameCodes = map[rune]int{
 	' ':       0,
 	'*':       1,
@@ -33,36 +71,159 @@ var nameCodes = map[rune]int{
 	'.':       10,
 	nameSep:   11,
 	nameIntro: 12,
+	'/':       13,
+	'<':       14,
+	'-':       15,
 }
 
 // instantiatedName returns the name of a newly instantiated function.
 func (t *translator) instantiatedName(qid qualifiedIdent, types []types.Type) (string, error) {
-	var sb strings.Builder
-	fmt.Fprintf(&sb, "instantiate%c", nameSep)
+	var pkg string
 	if qid.pkg != nil {
-		fmt.Fprintf(&sb, qid.pkg.Name())
+		pkg = t.pkgComponent(qid.pkg)
+	}
+	name := strings.Replace(qid.ident.Name, ".", fmt.Sprintf("%c%x", nameIntro, nameCodes['.']), 1)
+	if qid.pkg == nil && t.importer.isExportedInstantiation(t.findTypesObject(qid), types) {
+		return exportedInstantiatedName(name, types, t.qualifier()), nil
+	}
+	return encodeInstantiation(pkg, name, types, t.qualifier()), nil
+}
+
+// pkgComponent returns the package component of the names of the
+// instantiations of the generic functions and types of pkg, which
+// is not the package being translated. By default it is the name of
+// pkg, which is readable but may be that of other packages as well.
+func (t *translator) pkgComponent(pkg *types.Package) string {
+	if t.importer.opts.QualifyInstantiatedNames {
+		var sb strings.Builder
+		encodeName(&sb, t.importer.importPath(pkg))
+		return sb.String()
+	}
+	return pkg.Name()
+}
+
+// qualifier returns the qualifier with which the type arguments
+// are written in instantiated names. By default, types are qualified
+// by the paths of their packages as type checked, which for packages
+// found in GO2PATH are their names; with the QualifyInstantiatedNames
+// option, they are qualified by import path.
+func (t *translator) qualifier() types.Qualifier {
+	if !t.importer.opts.QualifyInstantiatedNames {
+		return nil
 	}
-	fmt.Fprintf(&sb, "%c%s", nameSep, qid.ident.Name)
-	for _, typ := range types {
+	return func(pkg *types.Package) string {
+		return t.importer.importPath(pkg)
+	}
+}
+
+// exportedInstantiatedName returns the exported name of the
+// instantiation of the generic type name with the type arguments
+// targs, which is declared in the package that declares name.
+// The type arguments are qualified by qf, as for encodeInstantiation.
+func exportedInstantiatedName(name string, targs []types.Type, qf types.Qualifier) string {
+	return "I" + strings.TrimPrefix(encodeInstantiation("", name, targs, qf), "i")
+}
+
+// encodeInstantiation returns the name of the instantiation of the
+// generic name declared in package pkg with the type arguments targs.
+// The type arguments are written with types.TypeString and qf; if qf
+// is nil, they are qualified by package path.
+func encodeInstantiation(pkg, name string, targs []types.Type, qf types.Qualifier) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "instantiate%c%s%c%s", nameSep, pkg, nameSep, name)
+	for _, typ := range targs {
 		sb.WriteRune(nameSep)
-		s := typ.String()
+		encodeName(&sb, types.TypeString(typ, qf))
+	}
+	return sb.String()
+}
 
-		// We have to uniquely translate s into a valid Go identifier.
-		// This is not possible in general but we assume that
-		// identifiers will not contain nameSep or nameIntro.
-		for _, r := range s {
-			if (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') && r != nameSep && r != nameIntro {
+// encodeName writes s to sb encoded as part of an identifier.
+func encodeName(sb *strings.Builder, s string) {
+	for _, r := range s {
+		if (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') && r != nameSep && r != nameIntro {
+			sb.WriteRune(r)
+		} else if code, ok := nameCodes[r]; ok {
+			fmt.Fprintf(sb, "%c%x", nameIntro, code)
+		} else {
+			fmt.Fprintf(sb, "%c%c%06x", nameIntro, nameUnicode, r)
+		}
+	}
+}
+
+// ParseInstantiatedName reverses the naming of instantiated functions
+// and types in translated code. It returns the name, or the import
+// path, of the package declaring the generic function or type (empty
+// for the package that was translated), the generic name, and the
+// type strings of the type
+// arguments. For a method with type parameters, the generic name is
+// written Type.Method. The ok result reports whether name is an
+// instantiated name.
+func ParseInstantiatedName(name string) (pkg, base string, targs []string, ok bool) {
+	parts := strings.Split(name, string(nameSep))
+	if len(parts) < 4 || (parts[0] != "instantiate" && parts[0] != "Instantiate") {
+		return "", "", nil, false
+	}
+	pkg, ok = decodeName(parts[1])
+	if !ok {
+		return "", "", nil, false
+	}
+	base, ok = decodeName(parts[2])
+	if !ok {
+		return "", "", nil, false
+	}
+	for _, part := range parts[3:] {
+		targ, ok := decodeName(part)
+		if !ok {
+			return "", "", nil, false
+		}
+		targs = append(targs, targ)
+	}
+	return pkg, base, targs, true
+}
+
+// decodeName decodes an encoded type argument.
+func decodeName(s string) (string, bool) {
+	var sb strings.Builder
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != nameIntro {
+			sb.WriteRune(rs[i])
+			continue
+		}
+		i++
+		if i >= len(rs) {
+			return "", false
+		}
+		if rs[i] == nameUnicode {
+			if i+6 >= len(rs) {
+				return "", false
+			}
+			r, err := strconv.ParseUint(string(rs[i+1:i+7]), 16, 32)
+			if err != nil {
+				return "", false
+			}
+			sb.WriteRune(rune(r))
+			i += 6
+			continue
+		}
+		code, err := strconv.ParseUint(string(rs[i]), 16, 8)
+		if err != nil {
+			return "", false
+		}
+		found := false
+		for r, c := range nameCodes {
+			if c == int(code) {
 				sb.WriteRune(r)
-			} else {
-				code, ok := nameCodes[r]
-				if !ok {
-					panic(fmt.Sprintf("%s: unexpected type string character %q in %q", t.fset.Position(qid.ident.Pos()), r, s))
-				}
-				fmt.Fprintf(&sb, "%c%x", nameIntro, code)
+				found = true
+				break
 			}
 		}
+		if !found {
+			return "", false
+		}
 	}
-	return sb.String(), nil
+	return sb.String(), true
 }
 
 // importableName returns a name that we define in each package, so that
@@ -0,0 +1,94 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"github.com/tdakkota/go2go/golib/token"
+	"github.com/tdakkota/go2go/golib/types"
+	"testing"
+)
+
+func TestInstantiatedNames(t *testing.T) {
+	pkg := types.NewPackage("example.com/m-1/p", "p")
+	named := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "T", nil), types.Typ[types.Int], nil)
+	intVar := types.NewVar(token.NoPos, nil, "", types.Typ[types.Int])
+	strVar := types.NewVar(token.NoPos, nil, "", types.Typ[types.String])
+
+	tests := []struct {
+		typ  types.Type
+		want string
+	}{
+		{types.Typ[types.Int], "int"},
+		{types.Typ[types.String], "string"},
+		{named, "example୮acom୮dm୮f1୮dp୮aT"},
+		{types.NewPointer(types.Typ[types.Int]), "୮1int"},
+		{types.NewPointer(named), "୮1example୮acom୮dm୮f1୮dp୮aT"},
+		{types.NewSlice(types.Typ[types.Int]), "୮6୮7int"},
+		{types.NewArray(types.Typ[types.Int], 3), "୮63୮7int"},
+		{types.NewMap(types.Typ[types.String], types.Typ[types.Int]), "map୮6string୮7int"},
+		{types.NewChan(types.SendRecv, types.Typ[types.Int]), "chan୮0int"},
+		{types.NewChan(types.SendOnly, types.Typ[types.Int]), "chan୮e୮f୮0int"},
+		{types.NewChan(types.RecvOnly, types.Typ[types.Int]), "୮e୮fchan୮0int"},
+		{types.NewSignature(nil, types.NewTuple(intVar), types.NewTuple(strVar), false), "func୮8int୮9୮0string"},
+		{types.NewSignature(nil, types.NewTuple(types.NewVar(token.NoPos, nil, "", types.NewSlice(types.Typ[types.Int]))), nil, true), "func୮8୮a୮a୮aint୮9"},
+		{types.NewStruct([]*types.Var{types.NewField(token.NoPos, nil, "f", types.Typ[types.Int], false)}, []string{`json:"f"`}), "struct୮4f୮0int୮0୮u000022json୮u00003a୮u00005c୮u000022f୮u00005c୮u000022୮u000022୮5"},
+		{types.NewInterfaceType(nil, nil).Complete(), "interface୮4୮5"},
+	}
+
+	seen := make(map[string]string)
+	for _, test := range tests {
+		name := encodeInstantiation("", "F", []types.Type{test.typ}, nil)
+		if want := "instantiate୦୦F୦" + test.want; name != want {
+			t.Errorf("%s: got name %s, want %s", test.typ, name, want)
+		}
+		if prev, ok := seen[name]; ok {
+			t.Errorf("%s and %s both named %s", prev, test.typ, name)
+		}
+		seen[name] = test.typ.String()
+
+		pkgName, base, targs, ok := ParseInstantiatedName(name)
+		if !ok || pkgName != "" || base != "F" || len(targs) != 1 || targs[0] != test.typ.String() {
+			t.Errorf("ParseInstantiatedName(%s) = %q, %q, %q, %t; want \"\", \"F\", [%q], true", name, pkgName, base, targs, ok, test.typ)
+		}
+	}
+
+	// Argument lists must not collide with each other either.
+	for _, a := range tests {
+		for _, b := range tests {
+			name := encodeInstantiation("q", "G", []types.Type{a.typ, b.typ}, nil)
+			if prev, ok := seen[name]; ok {
+				t.Errorf("(%s, %s) collides with %s", a.typ, b.typ, prev)
+			}
+			seen[name] = "(" + a.typ.String() + ", " + b.typ.String() + ")"
+
+			pkgName, base, targs, ok := ParseInstantiatedName(name)
+			if !ok || pkgName != "q" || base != "G" || len(targs) != 2 || targs[0] != a.typ.String() || targs[1] != b.typ.String() {
+				t.Errorf("ParseInstantiatedName(%s) = %q, %q, %q, %t", name, pkgName, base, targs, ok)
+			}
+		}
+	}
+
+	// Methods with type parameters are named Type.Method.
+	pkgName, base, targs, ok := ParseInstantiatedName("instantiate୦୦List୮aMap୦int୦string")
+	if !ok || pkgName != "" || base != "List.Map" || len(targs) != 2 || targs[0] != "int" || targs[1] != "string" {
+		t.Errorf("ParseInstantiatedName of method = %q, %q, %q, %t; want \"\", \"List.Map\", [int string], true", pkgName, base, targs, ok)
+	}
+
+	// Instantiations used by other packages have exported names.
+	name := exportedInstantiatedName("List", []types.Type{types.Typ[types.Int]}, nil)
+	if name != "Instantiate୦୦List୦int" {
+		t.Errorf("exportedInstantiatedName = %q, want %q", name, "Instantiate୦୦List୦int")
+	}
+	pkgName, base, targs, ok = ParseInstantiatedName(name)
+	if !ok || pkgName != "" || base != "List" || len(targs) != 1 || targs[0] != "int" {
+		t.Errorf("ParseInstantiatedName(%s) = %q, %q, %q, %t; want \"\", \"List\", [int], true", name, pkgName, base, targs, ok)
+	}
+
+	for _, name := range []string{"F", "instantiate୦୦F", "instantiate୦୦F୦୮", "instantiate୦୦F୦୮u12", "instantiate୦୦F୦୮x"} {
+		if _, _, _, ok := ParseInstantiatedName(name); ok {
+			t.Errorf("ParseInstantiatedName(%s) succeeded unexpectedly", name)
+		}
+	}
+}
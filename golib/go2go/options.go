@@ -0,0 +1,222 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/build"
+	"github.com/tdakkota/go2go/golib/printer"
+	"github.com/tdakkota/go2go/golib/types"
+	"path/filepath"
+	"strings"
+)
+
+// Options controls details of the translation.
+// The zero value selects the default behavior.
+type Options struct {
+	// KeepLineDirectives reports whether //line directives in the
+	// .go2 input should be honored. By default the //line directives
+	// written to the output refer to the .go2 file itself. If this is
+	// set, they refer to the positions named by the input directives,
+	// so that a debugger maps back to the true original source, and
+	// the input directives themselves are dropped from the output.
+	KeepLineDirectives bool
+
+	// Tabwidth is the width of an indentation level when indenting
+	// with spaces, and of the tab stops used for alignment.
+	// If zero, a width of 8 is used.
+	Tabwidth int
+
+	// IndentWithSpaces reports whether to indent with spaces
+	// rather than with tabs.
+	IndentWithSpaces bool
+
+	// TypeArgComments reports whether each call of an instantiated
+	// function should be followed by a comment listing the type
+	// arguments of the original call, as in /* Map(int, string) */.
+	TypeArgComments bool
+
+	// Validate reports whether to type check the files generated
+	// when translating a directory, and to report any errors as
+	// translation failures. This catches bugs in the translator
+	// before the generated code is handed to the compiler.
+	Validate bool
+
+	// SplitDecls, if positive, is the maximum number of declarations
+	// created by instantiation that are written to a single generated
+	// file when translating a directory. If a file needs more, the
+	// rest are written to additional files named foo.gen1.go,
+	// foo.gen2.go, and so on, next to foo.go.
+	SplitDecls int
+
+	// SplitBytes, if positive, is the approximate maximum size in
+	// bytes of a generated file when translating a directory. If a
+	// file would be larger, the declarations created by instantiation
+	// that do not fit are written to additional files, as for
+	// SplitDecls; the two options may be used together. A file may
+	// still be larger if the rest of its code is, and a declaration
+	// that is larger on its own is written to a file of its own.
+	SplitBytes int
+
+	// InstantiationsFile, if not empty, is the name of a .go file,
+	// such as instantiations.gen.go, to which the declarations
+	// created by instantiation for the files of a package are moved
+	// when translating a directory, rather than being written to the
+	// .go files generated for the files that need them. Each
+	// instantiation is made only once for a package, so the file
+	// holds all of them. The instantiations made only by test files
+	// stay in the generated test files, and SplitDecls and SplitBytes
+	// then apply only to those.
+	InstantiationsFile string
+
+	// GenerateCommand, if not empty, is a command that regenerates
+	// the output, such as "go2go translate". Each .go file written
+	// for a .go2 file then contains a //go:generate directive, just
+	// after the package clause, that runs the command with the name
+	// of the .go2 file as its argument.
+	GenerateCommand string
+
+	// IndexFile, if not empty, is the name of a .go file, such as
+	// specializations_gen.go, that is written when translating a
+	// directory. It declares a variable go2goSpecializations, a
+	// map[string]string from each instantiation made by the package,
+	// written as in Map(int, string), to the name of the function or
+	// type declared for it. Instantiations made only by test files
+	// are not included.
+	IndexFile string
+
+	// BuildContext, if not nil, selects the .go2 files that are
+	// translated when translating a directory: only files whose
+	// names and //go:build or +build lines match its GOOS, GOARCH
+	// and build tags are used. If nil, build.Default is used.
+	BuildContext *build.Context
+
+	// OnGenerate, if not nil, is called once for each instantiation
+	// of a generic function or type, with the declaration created for
+	// it, the generic function or type, and the type arguments. For a
+	// type, decl is the type declaration; the instantiated methods are
+	// not reported separately. OnGenerate is called before decl itself
+	// is translated, so instantiations within it are not yet rewritten.
+	OnGenerate func(decl ast.Decl, orig types.Object, targs []types.Type)
+
+	// PruneUnreachable reports whether to leave out of the output
+	// the declarations created by instantiation, and the unexported
+	// functions, that the rest of the package does not refer to,
+	// directly or indirectly. The exported declarations, the init
+	// and main functions, and all other declarations are kept.
+	// The files of a package are pruned together, so a declaration
+	// that is used only by a test file is kept.
+	PruneUnreachable bool
+
+	// QualifyInstantiatedNames reports whether to name the package
+	// that declares a generic function or type by its import path,
+	// rather than by its name, in the names of its instantiations,
+	// as in instantiate୦example୮acom୮dlist୦List୦int for List(int)
+	// of package example.com/list. The type arguments are always
+	// qualified by import path. This keeps the instantiations of
+	// generic functions and types of the same name, declared by
+	// packages of the same name, from colliding.
+	QualifyInstantiatedNames bool
+
+	// GuardTypeAssertions reports whether to check the single-value
+	// type assertions x.(T) in generic functions whose type T is a
+	// type parameter. In each instantiation such an assertion becomes
+	// a call of a function literal that, if the assertion fails,
+	// panics with a message naming the assertion and the
+	// instantiation, as in "go2go: type assertion x.(T) failed in
+	// Get(int)"; the message of the runtime names only the types.
+	// Comma-ok assertions and type switches are not changed.
+	GuardTypeAssertions bool
+
+	// StampSource reports whether to record in the header of each
+	// generated file, after the "Code generated" line, the version
+	// of go2go and the SHA-256 hash of the file it was translated
+	// from, as in
+	//
+	//	// go2go devel; source sha256:9f86d081884c7d65...
+	//
+	// A tool can compare the hash with that of the source file to
+	// tell whether the generated file is out of date.
+	StampSource bool
+
+	// SourceCopyExt, if not empty, is an extension, such as
+	// ".go2.orig", with which a copy of each translated file is
+	// written next to the .go file generated for it when translating
+	// a directory, as in foo.go2.orig for foo.go, so that the two can
+	// be compared. The extension must not be one that the go command
+	// reads, such as .go, nor one of the files to translate, such as
+	// .go2, or the copies would be translated in turn.
+	SourceCopyExt string
+
+	// Extensions, if not nil, maps the extension of each kind of
+	// file to translate, such as ".gox", to the suffix that replaces
+	// it in the name of the generated file, such as ".go" or
+	// "_gen.go". The suffix must end in ".go"; for a test file it is
+	// put before the _test, as in foo_gen_test.go. When translating
+	// a directory, only files with one of these extensions are
+	// translated. If nil, .go2 files are translated to .go files.
+	Extensions map[string]string
+}
+
+// SetOptions sets the options used when translating files,
+// including the files of imported Go 2 packages.
+func (imp *Importer) SetOptions(opts Options) {
+	imp.opts = opts
+}
+
+// isSourceFile reports whether the file named filename is to be
+// translated, according to its extension.
+func (imp *Importer) isSourceFile(filename string) bool {
+	ext := filepath.Ext(filename)
+	if imp.opts.Extensions == nil {
+		return ext == ".go2"
+	}
+	_, ok := imp.opts.Extensions[ext]
+	return ok
+}
+
+// goFileName returns the name of the .go file generated for filename.
+func (imp *Importer) goFileName(filename string) string {
+	ext := filepath.Ext(filename)
+	suffix, ok := imp.opts.Extensions[ext]
+	if !ok {
+		suffix = ".go"
+	}
+	base := strings.TrimSuffix(filename, ext)
+	if strings.HasSuffix(base, "_test") {
+		return strings.TrimSuffix(base, "_test") + strings.TrimSuffix(suffix, ".go") + "_test.go"
+	}
+	return base + suffix
+}
+
+// sourceCopyName returns the name of the copy of filename written
+// for the SourceCopyExt option.
+func (imp *Importer) sourceCopyName(filename string) string {
+	return strings.TrimSuffix(imp.goFileName(filename), ".go") + imp.opts.SourceCopyExt
+}
+
+// buildContext returns the build context that selects the files
+// to translate.
+func (imp *Importer) buildContext() *build.Context {
+	if imp.opts.BuildContext != nil {
+		return imp.opts.BuildContext
+	}
+	return &build.Default
+}
+
+// printerConfig returns the printer configuration for translated files.
+func (imp *Importer) printerConfig() *printer.Config {
+	cfg := config
+	if imp.opts.KeepLineDirectives {
+		cfg.Mode |= printer.AdjustedPos
+	}
+	if imp.opts.Tabwidth > 0 {
+		cfg.Tabwidth = imp.opts.Tabwidth
+	}
+	if imp.opts.IndentWithSpaces {
+		cfg.Mode &^= printer.TabIndent
+	}
+	return &cfg
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/token"
+)
+
+// pruneDecls removes from files, the translated files of a package,
+// the declarations that nothing in the package can reach. Only
+// declarations created by instantiation and unexported functions
+// other than init and main are removed; all other declarations are
+// where reachability starts. A declaration reaches the declarations
+// of the names it refers to, and a type reaches its methods.
+// Names are matched without regard to scope, so a declaration may
+// be kept that is not in fact reachable, but not the other way round.
+// pruneDecls returns the names of the removed declarations.
+func pruneDecls(files []*ast.File) map[string]bool {
+	// byName maps each name declared at package level, or the
+	// name of the receiver type of a method, to its declarations.
+	byName := make(map[string][]ast.Decl)
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			for _, name := range declNames(decl) {
+				byName[name] = append(byName[name], decl)
+			}
+		}
+	}
+
+	reached := make(map[ast.Decl]bool)
+	var work []ast.Decl
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			if !isPrunable(decl) {
+				reached[decl] = true
+				work = append(work, decl)
+			}
+		}
+	}
+	for len(work) > 0 {
+		decl := work[len(work)-1]
+		work = work[:len(work)-1]
+		ast.Inspect(decl, func(n ast.Node) bool {
+			id, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			for _, d := range byName[id.Name] {
+				if !reached[d] {
+					reached[d] = true
+					work = append(work, d)
+				}
+			}
+			return true
+		})
+	}
+
+	pruned := make(map[string]bool)
+	for _, f := range files {
+		decls := f.Decls[:0]
+		for _, decl := range f.Decls {
+			if reached[decl] {
+				decls = append(decls, decl)
+				continue
+			}
+			if fd, ok := decl.(*ast.FuncDecl); !ok || fd.Recv == nil {
+				for _, name := range declNames(decl) {
+					pruned[name] = true
+				}
+			}
+		}
+		f.Decls = decls
+	}
+	return pruned
+}
+
+// declNames returns the names declared by decl. For a method,
+// it returns the name of the receiver type.
+func declNames(decl ast.Decl) []string {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv != nil && len(decl.Recv.List) == 1 {
+			rtyp := decl.Recv.List[0].Type
+			if p, ok := rtyp.(*ast.StarExpr); ok {
+				rtyp = p.X
+			}
+			if id, ok := rtyp.(*ast.Ident); ok {
+				return []string{id.Name}
+			}
+			return nil
+		}
+		return []string{decl.Name.Name}
+	case *ast.GenDecl:
+		var names []string
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, spec.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					names = append(names, name.Name)
+				}
+			}
+		}
+		return names
+	default:
+		return nil
+	}
+}
+
+// isPrunable reports whether pruneDecls may remove decl.
+func isPrunable(decl ast.Decl) bool {
+	if isInstantiatedDecl(decl) {
+		return true
+	}
+	fd, ok := decl.(*ast.FuncDecl)
+	if !ok || fd.Recv != nil {
+		return false
+	}
+	name := fd.Name.Name
+	return !token.IsExported(name) && name != "init" && name != "main" && name != "_"
+}
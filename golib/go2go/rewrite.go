@@ -6,11 +6,15 @@ package go2go
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"github.com/tdakkota/go2go/golib/ast"
 	"github.com/tdakkota/go2go/golib/printer"
 	"github.com/tdakkota/go2go/golib/token"
 	"github.com/tdakkota/go2go/golib/types"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -52,6 +56,61 @@ func isParameterizedTypeDecl(s ast.Spec) bool {
 	return ts.TParams != nil
 }
 
+// needsTranslation reports whether file uses anything that the
+// translator rewrites: parameterized declarations, contracts,
+// instantiations, the predeclared any, or embedded fields that are
+// renamed when instantiated. A file that uses none of them is already
+// Go 1, apart from its imports.
+func needsTranslation(file *ast.File, info *types.Info) bool {
+	found := false
+	var embedded map[token.Pos]bool
+	ast.Inspect(file, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			found = isParameterizedFuncDecl(n, info)
+		case *ast.GenDecl:
+			// A contract.
+			found = n.Tok == token.IDENT
+		case *ast.TypeSpec:
+			found = isParameterizedTypeDecl(n)
+		case *ast.Ident:
+			obj := info.Uses[n]
+			if v, ok := obj.(*types.Var); ok && v.IsField() && v.Embedded() {
+				if embedded == nil {
+					embedded = embeddedTypeArgFields(info)
+				}
+				found = embedded[v.Pos()]
+			} else {
+				found = n.Name == "any" && obj == types.Universe.Lookup("any")
+			}
+		case *ast.CallExpr:
+			found = isGeneric(info.TypeOf(n.Fun))
+		case *ast.IndexExpr:
+			found = isGeneric(info.TypeOf(n.X))
+		case *ast.IndexListExpr:
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// isGeneric reports whether typ, the type of an expression, is that
+// of a generic function or type, which the expression names, so that
+// calling or indexing the expression instantiates it.
+func isGeneric(typ types.Type) bool {
+	switch typ := typ.(type) {
+	case *types.Signature:
+		return len(typ.TParams()) > 0
+	case *types.Named:
+		return len(typ.TParams()) > 0 && len(typ.TArgs()) == 0
+	}
+	return false
+}
+
 // A translator is used to translate a file from Go with contracts to Go 1.
 type translator struct {
 	fset               *token.FileSet
@@ -62,6 +121,37 @@ type translator struct {
 	newDecls           []ast.Decl
 	typeInstantiations map[types.Type][]*typeInstantiation
 
+	// comments are comments added by the translation, if any.
+	comments []*ast.CommentGroup
+
+	// instantiating is set while translating the declarations
+	// created by instantiating generic functions and types.
+	instantiating bool
+
+	// instStack lists the instantiations in progress, outermost
+	// first. It is reported if the translation panics.
+	instStack []instContext
+
+	// declInsts records the instantiations that led to each
+	// declaration created by instantiation.
+	declInsts map[ast.Decl][]instContext
+
+	// importPaths maps each name declared by an import in the
+	// translated file to the path of the imported package.
+	importPaths map[string]string
+
+	// pkgNames maps the path of each package imported by the
+	// translated file to the name used to refer to it in code
+	// copied from other files. aliased records the paths for
+	// which that name is an alias rather than the package name.
+	pkgNames map[string]string
+	aliased  map[string]bool
+
+	// embeddedTypeArgs records the positions of the fields that
+	// embed a type parameter or an instantiated type. It is built
+	// when first needed by embedsTypeArg.
+	embeddedTypeArgs map[token.Pos]bool
+
 	// err is set if we have seen an error during this translation.
 	// This is used by the rewrite methods.
 	err error
@@ -69,26 +159,118 @@ type translator struct {
 
 // An instantiation is a single instantiation of a function.
 type instantiation struct {
-	types []types.Type
-	decl  *ast.Ident
+	types    []types.Type
+	decl     *ast.Ident
+	external bool // registered by RegisterSpecialization
 }
 
 // A typeInstantiation is a single instantiation of a type.
 type typeInstantiation struct {
-	types []types.Type
-	decl  *ast.Ident
-	typ   types.Type
+	types    []types.Type
+	decl     *ast.Ident
+	typ      types.Type
+	external bool // registered by RegisterSpecialization; typ is nil
 }
 
-// rewrite rewrites the contents of one file.
-func rewriteFile(dir string, fset *token.FileSet, importer *Importer, importPath string, tpkg *types.Package, filename string, file *ast.File, addImportableName bool) (err error) {
-	if err := rewriteAST(fset, importer, importPath, tpkg, file, addImportableName); err != nil {
-		return err
+// An instantiationCache records the instantiations made while
+// translating the files of a single package. The files share it,
+// so that each instantiation is declared only once in the package.
+type instantiationCache struct {
+	types              map[ast.Expr]types.Type
+	instantiations     map[string][]*instantiation
+	typeInstantiations map[types.Type][]*typeInstantiation
+}
+
+// newInstantiationCache returns an instantiationCache for translating
+// the package tpkg, holding the specializations registered with importer.
+func newInstantiationCache(importer *Importer, tpkg *types.Package) *instantiationCache {
+	cache := &instantiationCache{
+		types:              make(map[ast.Expr]types.Type),
+		instantiations:     make(map[string][]*instantiation),
+		typeInstantiations: make(map[types.Type][]*typeInstantiation),
+	}
+	for _, spec := range importer.specializations {
+		switch orig := spec.Orig.(type) {
+		case *types.Func:
+			key := orig.Name()
+			if orig.Pkg() != tpkg {
+				key = importer.importPath(orig.Pkg()) + "." + key
+			}
+			cache.instantiations[key] = append(cache.instantiations[key], &instantiation{
+				types:    spec.TArgs,
+				decl:     ast.NewIdent(spec.Name),
+				external: true,
+			})
+		case *types.TypeName:
+			typ := orig.Type()
+			cache.typeInstantiations[typ] = append(cache.typeInstantiations[typ], &typeInstantiation{
+				types:    spec.TArgs,
+				decl:     ast.NewIdent(spec.Name),
+				external: true,
+			})
+		}
+	}
+	return cache
+}
+
+// testHookRewrite, if not nil, is called with each translated file
+// before it is written out. Tests use it to simulate translation bugs.
+var testHookRewrite func(*ast.File)
+
+// testForceTranslate, if set, makes rewriteAST translate files
+// that need no translation. Tests use it to compare the output
+// of the fast path with that of a full translation.
+var testForceTranslate bool
+
+// writeRewrittenFile writes file, rewritten by rewriteAST, to the
+// .go file in dir that corresponds to filename.
+func writeRewrittenFile(dir string, fset *token.FileSet, importer *Importer, filename string, file *ast.File) error {
+	if testHookRewrite != nil {
+		testHookRewrite(file)
+	}
+
+	var extra []*ast.File
+	if importer.opts.SplitDecls > 0 || importer.opts.SplitBytes > 0 {
+		var err error
+		extra, err = splitFile(fset, importer, file)
+		if err != nil {
+			return err
+		}
 	}
 
 	filename = filepath.Base(filename)
-	goFile := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".go"
-	o, err := os.Create(filepath.Join(dir, goFile))
+	if err := writeGoFile(filepath.Join(dir, importer.goFileName(filename)), fset, importer, file); err != nil {
+		return err
+	}
+	for i, f := range extra {
+		if err := writeGoFile(filepath.Join(dir, splitFileName(filename, i+1)), fset, importer, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copySourceFile copies filename, a translated file, to the file
+// in dir named for it by the SourceCopyExt option.
+func copySourceFile(dir string, importer *Importer, filename string) error {
+	ext := importer.opts.SourceCopyExt
+	if strings.HasSuffix(ext, ".go") {
+		return fmt.Errorf("source copy extension %q ends in .go", ext)
+	}
+	if importer.isSourceFile(ext) {
+		return fmt.Errorf("source copy extension %q names files to translate", ext)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	name := importer.sourceCopyName(filepath.Base(filename))
+	return ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+}
+
+// writeGoFile writes the translated file to the file named filename.
+func writeGoFile(filename string, fset *token.FileSet, importer *Importer, file *ast.File) (err error) {
+	o, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
@@ -104,22 +286,131 @@ func rewriteFile(dir string, fset *token.FileSet, importer *Importer, importPath
 			err = flushErr
 		}
 	}()
-	fmt.Fprintln(w, rewritePrefix)
+	return printGoFile(w, fset, importer, file)
+}
+
+// printGoFile prints the translated file to w, preceded by
+// rewritePrefix and, if requested, the stamp of its source. If
+// requested, it adds a //go:generate directive on its own line
+// after the package clause.
+func printGoFile(w io.Writer, fset *token.FileSet, importer *Importer, file *ast.File) error {
+	fmt.Fprint(w, rewritePrefix)
+	if importer.opts.StampSource {
+		stamp, err := sourceStamp(fset, importer, file)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n\n", stamp)
+	}
+	fmt.Fprintln(w)
+
+	cmd := importer.opts.GenerateCommand
+	if cmd == "" {
+		return printFile(w, fset, importer, file)
+	}
+
+	var buf bytes.Buffer
+	if err := printFile(&buf, fset, importer, file); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+	end := 0
+	for end < len(out) {
+		line := out[end:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+		end += len(line)
+		if bytes.HasPrefix(line, []byte("package ")) {
+			break
+		}
+	}
+	filename := filepath.Base(fset.Position(file.Package).Filename)
+	if _, err := w.Write(out[:end]); err != nil {
+		return err
+	}
+	if end > 0 && out[end-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "\n//go:generate %s %s\n", cmd, filename)
+	_, err := w.Write(out[end:])
+	return err
+}
+
+// sourceStamp returns the comment recording the version of go2go
+// and the SHA-256 hash of the source of file, as in
+//
+//	// go2go devel; source sha256:9f86d081884c7d65...
+func sourceStamp(fset *token.FileSet, importer *Importer, file *ast.File) (string, error) {
+	tf := fset.File(file.Package)
+	sum, ok := importer.srcHashes[tf]
+	if !ok {
+		data, err := ioutil.ReadFile(tf.Name())
+		if err != nil {
+			return "", err
+		}
+		sum = sha256.Sum256(data)
+	}
+	return fmt.Sprintf("// go2go %s; source sha256:%x", Version, sum), nil
+}
+
+// printFile prints file to w. The comments of the file are
+// printed in source order along with the declarations, so a
+// function created by instantiation, which is out of order and
+// may come from another file, is printed on its own along with
+// the comments of the function from which it was created.
+func printFile(w io.Writer, fset *token.FileSet, importer *Importer, file *ast.File) error {
+	cfg := importer.printerConfig()
+	i := 0
+	for ; i < len(file.Decls); i++ {
+		if fd, ok := file.Decls[i].(*ast.FuncDecl); ok && importer.funcComments[fd] != nil {
+			break
+		}
+	}
+	if i == len(file.Decls) {
+		return cfg.Fprint(w, fset, file)
+	}
 
-	return config.Fprint(w, fset, file)
+	head := *file
+	head.Decls = file.Decls[:i]
+	if err := cfg.Fprint(w, fset, &head); err != nil {
+		return err
+	}
+	for _, decl := range file.Decls[i:] {
+		var node interface{} = decl
+		if fd, ok := decl.(*ast.FuncDecl); ok && importer.funcComments[fd] != nil {
+			node = &printer.CommentedNode{Node: fd, Comments: importer.funcComments[fd]}
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+		if err := cfg.Fprint(w, fset, node); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // rewriteAST rewrites the AST for a file.
-func rewriteAST(fset *token.FileSet, importer *Importer, importPath string, tpkg *types.Package, file *ast.File, addImportableName bool) (err error) {
+// Instantiations are looked up in and added to cache.
+func rewriteAST(fset *token.FileSet, importer *Importer, importPath string, tpkg *types.Package, file *ast.File, cache *instantiationCache, addImportableName bool) (err error) {
 	t := translator{
 		fset:               fset,
 		importer:           importer,
 		tpkg:               tpkg,
-		types:              make(map[ast.Expr]types.Type),
-		instantiations:     make(map[string][]*instantiation),
-		typeInstantiations: make(map[types.Type][]*typeInstantiation),
+		types:              cache.types,
+		instantiations:     cache.instantiations,
+		typeInstantiations: cache.typeInstantiations,
+		declInsts:          make(map[ast.Decl][]instContext),
 	}
-	t.translate(file)
+	defer func() {
+		if r := recover(); r != nil {
+			err = t.panicError(r)
+		}
+	}()
 
 	// Add all the transitive imports. This is more than we need,
 	// but we're not trying to be elegant here.
@@ -129,6 +420,67 @@ func rewriteAST(fset *token.FileSet, importer *Importer, importPath string, tpkg
 		imps[p] = true
 	}
 
+	for _, imp := range file.Imports {
+		// We picked up Go 2 imports above, but we still
+		// need to pick up Go 1 imports here.
+		path := strings.TrimPrefix(strings.TrimSuffix(imp.Path.Value, `"`), `"`)
+		if imps[path] {
+			continue
+		}
+		imps[path] = true
+		for _, p := range importer.transitiveImports(path) {
+			imps[p] = true
+		}
+	}
+
+	paths := make([]string, 0, len(imps))
+	for p := range imps {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	if err := t.assignImportNames(file, paths); err != nil {
+		return err
+	}
+	importer.importNames[file] = t.pkgNames
+
+	// A dot import is replaced by a regular import, so references
+	// to the names it imports must be qualified. Only the original
+	// declarations that remain after translation are qualified;
+	// code created by instantiation is qualified as it is copied.
+	var origDecls []ast.Decl
+	hasDotImport := false
+	for _, imp := range file.Imports {
+		if imp.Name != nil && imp.Name.Name == "." {
+			hasDotImport = true
+			origDecls = append(origDecls, file.Decls...)
+			break
+		}
+	}
+
+	// A file without generics needs only its imports fixed up.
+	if testForceTranslate || needsTranslation(file, importer.info) {
+		t.translate(file)
+	}
+
+	if hasDotImport {
+		t.qualifyDotImports(file, origDecls)
+	}
+
+	// When honoring the //line directives of the input, the printer
+	// writes directives for the positions they name, so drop the
+	// input directives. Otherwise they are kept as comments.
+	if importer.opts.KeepLineDirectives {
+		file.Comments = filterLineDirectives(file.Comments)
+	}
+
+	if len(t.comments) > 0 {
+		file.Comments = append(file.Comments, t.comments...)
+		sort.Slice(file.Comments, func(i, j int) bool {
+			return file.Comments[i].Pos() < file.Comments[j].Pos()
+		})
+	}
+
 	decls := make([]ast.Decl, 0, len(file.Decls))
 	var specs []ast.Spec
 	for _, decl := range file.Decls {
@@ -139,36 +491,24 @@ func rewriteAST(fset *token.FileSet, importer *Importer, importPath string, tpkg
 		}
 		for _, spec := range gen.Specs {
 			imp := spec.(*ast.ImportSpec)
-			if imp.Name != nil {
+			if imp.Name != nil && imp.Name.Name != "." {
 				specs = append(specs, imp)
 			}
-			// We picked up Go 2 imports above, but we still
-			// need to pick up Go 1 imports here.
-			path := strings.TrimPrefix(strings.TrimSuffix(imp.Path.Value, `"`), `"`)
-			if imps[path] {
-				continue
-			}
-			imps[path] = true
-			for _, p := range importer.transitiveImports(path) {
-				imps[p] = true
-			}
 		}
 	}
 	file.Decls = decls
 
-	paths := make([]string, 0, len(imps))
-	for p := range imps {
-		paths = append(paths, p)
-	}
-	sort.Strings(paths)
-
 	for _, p := range paths {
-		specs = append(specs, ast.Spec(&ast.ImportSpec{
+		spec := &ast.ImportSpec{
 			Path: &ast.BasicLit{
 				Kind:  token.STRING,
 				Value: strconv.Quote(p),
 			},
-		}))
+		}
+		if t.aliased[p] {
+			spec.Name = ast.NewIdent(t.pkgNames[p])
+		}
+		specs = append(specs, spec)
 	}
 	if len(specs) > 0 {
 		first := &ast.GenDecl{
@@ -250,7 +590,7 @@ func rewriteAST(fset *token.FileSet, importer *Importer, importPath string, tpkg
 			if imp.Name != nil {
 				name = imp.Name.Name
 			} else {
-				name = filepath.Base(path)
+				name = t.pkgNames[path]
 			}
 			var spec ast.Spec
 			switch tok {
@@ -288,6 +628,177 @@ func rewriteAST(fset *token.FileSet, importer *Importer, importPath string, tpkg
 	return t.err
 }
 
+// assignImportNames decides the names under which the translated
+// file refers to the packages in paths, all of which it imports.
+// The file's own imports keep their names. Any other package is
+// referred to by its package name, unless that is already in use,
+// in which case it gets the first free alias formed by adding a
+// number, as in util2. The paths are sorted, so the aliases are
+// the same each time the file is translated.
+func (t *translator) assignImportNames(file *ast.File, paths []string) error {
+	t.importPaths = make(map[string]string)
+	t.pkgNames = make(map[string]string)
+	t.aliased = make(map[string]bool)
+	for _, imp := range file.Imports {
+		path := strings.TrimPrefix(strings.TrimSuffix(imp.Path.Value, `"`), `"`)
+		if imp.Name != nil {
+			if imp.Name.Name != "_" && imp.Name.Name != "." {
+				t.importPaths[imp.Name.Name] = path
+			}
+			continue
+		}
+		name, err := t.packageName(file, path)
+		if err != nil {
+			return err
+		}
+		t.importPaths[name] = path
+		t.pkgNames[path] = name
+	}
+	for _, path := range paths {
+		if _, ok := t.pkgNames[path]; ok {
+			continue
+		}
+		name, err := t.packageName(file, path)
+		if err != nil {
+			return err
+		}
+		alias := name
+		for n := 2; t.importPaths[alias] != "" || t.tpkg.Scope().Lookup(alias) != nil; n++ {
+			alias = fmt.Sprintf("%s%d", name, n)
+		}
+		t.importPaths[alias] = path
+		t.pkgNames[path] = alias
+		t.aliased[path] = alias != name
+	}
+	return nil
+}
+
+// packageName returns the name of the package imported by file
+// with the given path.
+func (t *translator) packageName(file *ast.File, path string) (string, error) {
+	if pkg, ok := t.importer.lookupPackage(path); ok {
+		return pkg.Name(), nil
+	}
+	fileDir := filepath.Dir(t.fset.Position(file.Name.Pos()).Filename)
+	pkg, err := t.importer.ImportFrom(path, fileDir, 0)
+	if err != nil {
+		return "", err
+	}
+	return pkg.Name(), nil
+}
+
+// pkgIdent returns the identifier to use in the translated file
+// for id, which refers to the imported package pn in code copied
+// from the declaration being instantiated.
+func (t *translator) pkgIdent(id *ast.Ident, pn *types.PkgName) *ast.Ident {
+	path := t.importer.importPath(pn.Imported())
+	if t.importPaths[id.Name] == path {
+		return id
+	}
+	name, ok := t.pkgNames[path]
+	if !ok {
+		return id
+	}
+	return &ast.Ident{NamePos: id.NamePos, Name: name}
+}
+
+// qualifiedName returns the name, qualified by the name of its
+// package, by which the translated file refers to obj, and reports
+// whether obj needs qualifying: whether it is an exported object
+// declared at package level by a package other than the one being
+// translated. Such an object is referred to by an unqualified name
+// in code copied from a generic function or type of its package,
+// and in code that dot imports its package. Generic functions and
+// types are not qualified, as they are replaced by instantiations.
+func (t *translator) qualifiedName(obj types.Object) (string, bool) {
+	pkg := obj.Pkg()
+	if pkg == nil || pkg == t.tpkg || !obj.Exported() || pkg.Scope().Lookup(obj.Name()) != obj {
+		return "", false
+	}
+	switch typ := obj.Type().(type) {
+	case *types.Signature:
+		if len(typ.TParams()) > 0 {
+			return "", false
+		}
+	case *types.Named:
+		if _, ok := obj.(*types.TypeName); ok && len(typ.TParams()) > 0 {
+			return "", false
+		}
+	}
+	name, ok := t.pkgNames[t.importer.importPath(pkg)]
+	if !ok {
+		return "", false
+	}
+	return name + "." + obj.Name(), true
+}
+
+// qualifyDotImports qualifies the references to the names of the
+// dot imported packages in those of decls, the declarations of file
+// before translation, that are still in file.
+func (t *translator) qualifyDotImports(file *ast.File, decls []ast.Decl) {
+	kept := make(map[ast.Decl]bool, len(file.Decls))
+	for _, decl := range file.Decls {
+		kept[decl] = true
+	}
+	sels := make(map[*ast.Ident]bool)
+	for _, decl := range decls {
+		if !kept[decl] {
+			continue
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.SelectorExpr:
+				sels[n.Sel] = true
+			case *ast.Ident:
+				if obj := t.importer.info.Uses[n]; obj != nil && !sels[n] {
+					if name, ok := t.qualifiedName(obj); ok {
+						n.Name = name
+					}
+				}
+			}
+			return true
+		})
+	}
+}
+
+// filterLineDirectives returns comments with any //line or /*line
+// directives removed.
+func filterLineDirectives(comments []*ast.CommentGroup) []*ast.CommentGroup {
+	var r []*ast.CommentGroup
+	for _, cg := range comments {
+		var list []*ast.Comment
+		for _, c := range cg.List {
+			if !strings.HasPrefix(c.Text, "//line ") && !strings.HasPrefix(c.Text, "/*line ") {
+				list = append(list, c)
+			}
+		}
+		switch len(list) {
+		case 0:
+		case len(cg.List):
+			r = append(r, cg)
+		default:
+			r = append(r, &ast.CommentGroup{List: list})
+		}
+	}
+	return r
+}
+
+// dropComments removes from file the comments within n and its
+// documentation doc, if any, as n is not part of the translation.
+func dropComments(file *ast.File, doc *ast.CommentGroup, n ast.Node) {
+	beg, end := n.Pos(), n.End()
+	if doc != nil {
+		beg = doc.Pos()
+	}
+	var r []*ast.CommentGroup
+	for _, cg := range file.Comments {
+		if cg.End() <= beg || cg.Pos() >= end {
+			r = append(r, cg)
+		}
+	}
+	file.Comments = r
+}
+
 // translate translates the AST for a file from Go with contracts to Go 1.
 func (t *translator) translate(file *ast.File) {
 	declsToDo := file.Decls
@@ -295,11 +806,14 @@ func (t *translator) translate(file *ast.File) {
 	for len(declsToDo) > 0 {
 		newDecls := make([]ast.Decl, 0, len(declsToDo))
 		for i, decl := range declsToDo {
+			t.instStack = t.declInsts[decl]
 			switch decl := decl.(type) {
 			case *ast.FuncDecl:
 				if !isParameterizedFuncDecl(decl, t.importer.info) {
 					t.translateFuncDecl(&declsToDo[i])
 					newDecls = append(newDecls, decl)
+				} else {
+					dropComments(file, decl.Doc, decl)
 				}
 			case *ast.GenDecl:
 				switch decl.Tok {
@@ -309,9 +823,16 @@ func (t *translator) translate(file *ast.File) {
 						if !isParameterizedTypeDecl(decl.Specs[j]) {
 							t.translateTypeSpec(&decl.Specs[j])
 							newSpecs = append(newSpecs, decl.Specs[j])
+						} else {
+							ts := decl.Specs[j].(*ast.TypeSpec)
+							dropComments(file, ts.Doc, ts)
+							if ts.Comment != nil {
+								dropComments(file, nil, ts.Comment)
+							}
 						}
 					}
 					if len(newSpecs) == 0 {
+						dropComments(file, decl.Doc, decl)
 						decl = nil
 					} else {
 						decl.Specs = newSpecs
@@ -322,6 +843,7 @@ func (t *translator) translate(file *ast.File) {
 					}
 				case token.IDENT:
 					// A contract.
+					dropComments(file, decl.Doc, decl)
 					decl = nil
 				}
 				if decl != nil {
@@ -331,9 +853,11 @@ func (t *translator) translate(file *ast.File) {
 				newDecls = append(newDecls, decl)
 			}
 		}
+		t.instStack = nil
 		file.Decls = append(file.Decls, newDecls...)
 		declsToDo = t.newDecls
 		t.newDecls = nil
+		t.instantiating = true
 	}
 }
 
@@ -484,6 +1008,24 @@ func (t *translator) translateExpr(pe *ast.Expr) {
 	}
 	switch e := (*pe).(type) {
 	case *ast.Ident:
+		if v, ok := t.importer.info.Uses[e].(*types.Var); ok {
+			if name, ok := t.embeddedFieldName(v, v.Type()); ok {
+				*pe = &ast.Ident{NamePos: e.NamePos, Name: name}
+			}
+			break
+		}
+		// Go 1 has no predeclared any; spell it out.
+		if obj := t.importer.info.Uses[e]; obj != nil && obj == types.Universe.Lookup("any") {
+			iface := &ast.InterfaceType{
+				Interface: e.Pos(),
+				Methods: &ast.FieldList{
+					Opening: e.Pos(),
+					Closing: e.Pos(),
+				},
+			}
+			t.setType(iface, obj.Type())
+			*pe = iface
+		}
 	case *ast.Ellipsis:
 		t.translateExpr(&e.Elt)
 	case *ast.BasicLit:
@@ -499,9 +1041,21 @@ func (t *translator) translateExpr(pe *ast.Expr) {
 		t.translateExpr(&e.X)
 	case *ast.SelectorExpr:
 		t.translateExpr(&e.X)
+		if v, ok := t.importer.info.Uses[e.Sel].(*types.Var); ok {
+			if name, ok := t.embeddedFieldName(v, v.Type()); ok {
+				e.Sel = &ast.Ident{NamePos: e.Sel.NamePos, Name: name}
+			}
+		}
 	case *ast.IndexExpr:
+		if isGeneric(t.lookupType(e.X)) {
+			t.translateIndexInstantiation(pe, e.X, e.Lbrack, []ast.Expr{e.Index}, e.Rbrack)
+			break
+		}
 		t.translateExpr(&e.X)
 		t.translateExpr(&e.Index)
+	case *ast.IndexListExpr:
+		// Only an instantiation has more than one index.
+		t.translateIndexInstantiation(pe, e.X, e.Lbrack, e.Indices, e.Rbrack)
 	case *ast.SliceExpr:
 		t.translateExpr(&e.X)
 		t.translateExpr(&e.Low)
@@ -511,13 +1065,31 @@ func (t *translator) translateExpr(pe *ast.Expr) {
 		t.translateExpr(&e.X)
 		t.translateExpr(&e.Type)
 	case *ast.CallExpr:
+		if sel, inst := t.genericMethodCall(e); sel != nil {
+			t.translateMethodInstantiation(pe, sel, inst)
+			break
+		}
+		// In a conversion to an instantiated type, such as
+		// List(int)(y), Fun is the call List(int). The type
+		// of List is an uninstantiated *types.Named, so the
+		// inner call is translated as a type instantiation
+		// and the outer call remains a conversion.
 		t.translateExprList(e.Args)
 		if ftyp, ok := t.lookupType(e.Fun).(*types.Signature); ok && len(ftyp.TParams()) > 0 {
 			t.translateFunctionInstantiation(pe)
 		} else if ntyp, ok := t.lookupType(e.Fun).(*types.Named); ok && len(ntyp.TParams()) > 0 && len(ntyp.TArgs()) == 0 {
 			t.translateTypeInstantiation(pe)
 		}
+		_, funCall := e.Fun.(*ast.CallExpr)
+		n := len(t.comments)
 		t.translateExpr(&e.Fun)
+		if funCall {
+			// Move a comment for an explicit instantiation
+			// such as f(int) after the arguments of the call.
+			for _, c := range t.comments[n:] {
+				c.List[0].Slash = e.End()
+			}
+		}
 	case *ast.StarExpr:
 		t.translateExpr(&e.X)
 	case *ast.UnaryExpr:
@@ -532,6 +1104,19 @@ func (t *translator) translateExpr(pe *ast.Expr) {
 		t.translateExpr(&e.Elt)
 	case *ast.StructType:
 		t.translateFieldList(e.Fields)
+		for _, f := range e.Fields.List {
+			// An embedded instantiated type is written in
+			// parentheses, which Go 1 does not permit.
+			if len(f.Names) == 0 {
+				for {
+					p, ok := f.Type.(*ast.ParenExpr)
+					if !ok {
+						break
+					}
+					f.Type = p.X
+				}
+			}
+		}
 	case *ast.FuncType:
 		t.translateFieldList(e.TParams)
 		t.translateFieldList(e.Params)
@@ -570,20 +1155,22 @@ func splitFieldList(fl *ast.FieldList) (methods *ast.FieldList, types []ast.Expr
 	return
 }
 
-// TODO(iant) refactor code and get rid of this?
-func mergeFieldList(methods *ast.FieldList, types []ast.Expr) (fl *ast.FieldList) {
-	fl = methods
-	if len(types) == 0 {
-		return
-	}
-	if fl == nil {
-		fl = new(ast.FieldList)
+// translateIndexInstantiation translates *pe, an instantiation of
+// the generic function or type x written with index syntax, as in
+// F[int] or Pair[K, V]. It rewrites *pe to call syntax, F(int) or
+// Pair(K, V), and translates that.
+func (t *translator) translateIndexInstantiation(pe *ast.Expr, x ast.Expr, lbrack token.Pos, targs []ast.Expr, rbrack token.Pos) {
+	call := &ast.CallExpr{
+		Fun:    x,
+		Lparen: lbrack,
+		Args:   targs,
+		Rparen: rbrack,
 	}
-	name := []*ast.Ident{ast.NewIdent("type")}
-	for _, typ := range types {
-		fl.List = append(fl.List, &ast.Field{Names: name, Type: typ})
+	if typ := t.lookupType(*pe); typ != nil {
+		t.setType(call, typ)
 	}
-	return
+	*pe = call
+	t.translateExpr(pe)
 }
 
 // translateExprList translate an expression list from Go with
@@ -614,10 +1201,24 @@ func (t *translator) translateField(f *ast.Field) {
 func (t *translator) translateFunctionInstantiation(pe *ast.Expr) {
 	call := (*pe).(*ast.CallExpr)
 	qid := t.instantiatedIdent(call)
+	if qid.pkg != nil {
+		if obj := t.findTypesObject(qid); obj != nil {
+			if _, ok := t.importer.lookupFunc(obj); !ok {
+				t.err = t.noSourceError(call, qid)
+				return
+			}
+		}
+	}
 	argList, typeList, typeArgs := t.instantiationTypes(call)
+	if fn, ok := t.findTypesObject(qid).(*types.Func); ok {
+		if err := t.typeArgCountError(call, qid, typeList, fn.Type().(*types.Signature).TParams()); err != nil {
+			t.err = err
+			return
+		}
+	}
 
 	var instIdent *ast.Ident
-	key := qid.String()
+	key := t.instKey(qid)
 	instantiations := t.instantiations[key]
 	for _, inst := range instantiations {
 		if t.sameTypes(typeList, inst.types) {
@@ -626,9 +1227,18 @@ func (t *translator) translateFunctionInstantiation(pe *ast.Expr) {
 		}
 	}
 
-	if instIdent == nil {
+	if instIdent != nil {
+		t.importer.stats.FuncHits++
+	} else {
+		t.importer.stats.FuncMisses++
+		if err := t.instDepthError(call, qid); err != nil {
+			t.err = err
+			return
+		}
 		var err error
+		t.pushInst(qid, typeList)
 		instIdent, err = t.instantiateFunction(qid, argList, typeList)
+		t.popInst()
 		if err != nil {
 			t.err = err
 			return
@@ -641,6 +1251,12 @@ func (t *translator) translateFunctionInstantiation(pe *ast.Expr) {
 		t.instantiations[key] = append(instantiations, n)
 	}
 
+	// Calls in instantiated code share the positions of the generic
+	// function body, so there is no place to put a comment for them.
+	if t.importer.opts.TypeArgComments && !t.instantiating {
+		t.addTypeArgComment(call, typeList)
+	}
+
 	if typeArgs {
 		*pe = instIdent
 	} else {
@@ -650,25 +1266,209 @@ func (t *translator) translateFunctionInstantiation(pe *ast.Expr) {
 	}
 }
 
+// genericMethodCall reports whether call calls a method that has
+// type parameters of its own, as in x.M(v) or x.M(int)(v). If so,
+// it returns the method selector and the call that instantiates the
+// method, which is call itself if the type arguments are inferred.
+// A call that is the explicit instantiation x.M(int) itself, without
+// a call of the result, is also reported.
+func (t *translator) genericMethodCall(call *ast.CallExpr) (*ast.SelectorExpr, *ast.CallExpr) {
+	inst := call
+	if c, ok := call.Fun.(*ast.CallExpr); ok {
+		if _, inferred := t.importer.info.Inferred[c]; !inferred {
+			inst = c
+		}
+	}
+	sel, ok := inst.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, nil
+	}
+	if ftyp, ok := t.lookupType(sel).(*types.Signature); !ok || len(ftyp.TParams()) == 0 {
+		return nil, nil
+	}
+	if f, ok := t.importer.info.Uses[sel.Sel].(*types.Func); !ok || f.Type().(*types.Signature).Recv() == nil {
+		return nil, nil
+	}
+	return sel, inst
+}
+
+// translateMethodInstantiation translates a call of a method with
+// type parameters to Go 1, which has no such methods. The method is
+// instantiated as a function that takes the receiver as its first
+// argument, so x.M(v) becomes instantiate୦୦T୮aM୦int(x, v).
+func (t *translator) translateMethodInstantiation(pe *ast.Expr, sel *ast.SelectorExpr, inst *ast.CallExpr) {
+	call := (*pe).(*ast.CallExpr)
+	if _, inferred := t.importer.info.Inferred[call]; call == inst && !inferred {
+		t.err = fmt.Errorf("%s: cannot use method %s with type arguments as a value", t.fset.Position(call.Pos()), sel.Sel.Name)
+		return
+	}
+	if tv, ok := t.importer.info.Types[sel.X]; ok && tv.IsType() {
+		t.err = fmt.Errorf("%s: cannot instantiate method expression %s", t.fset.Position(sel.Pos()), sel.Sel.Name)
+		return
+	}
+
+	t.translateExprList(call.Args)
+	argList, typeList, _ := t.instantiationTypes(inst)
+
+	xtyp := t.lookupType(sel.X)
+	recvPtr := false
+	if p, ok := xtyp.(*types.Pointer); ok {
+		xtyp = p.Elem()
+		recvPtr = true
+	}
+	recv, ok := xtyp.(*types.Named)
+	if !ok {
+		t.err = fmt.Errorf("%s: cannot instantiate method %s of %s", t.fset.Position(sel.Pos()), sel.Sel.Name, xtyp)
+		return
+	}
+	recvArgs, recvTypes := t.typeArgExprs(recv.TArgs())
+	qid, method, mdecl, err := t.findMethodDecl(recv, sel.Sel.Name)
+	if err != nil {
+		t.err = fmt.Errorf("%s: %v", t.fset.Position(sel.Pos()), err)
+		return
+	}
+	typeList = append(recvTypes, typeList...)
+
+	var instIdent *ast.Ident
+	key := t.instKey(qid)
+	instantiations := t.instantiations[key]
+	for _, inst := range instantiations {
+		if t.sameTypes(typeList, inst.types) {
+			instIdent = inst.decl
+			break
+		}
+	}
+
+	if instIdent != nil {
+		t.importer.stats.FuncHits++
+	} else {
+		t.importer.stats.FuncMisses++
+		if err := t.instDepthError(inst, qid); err != nil {
+			t.err = err
+			return
+		}
+		var err error
+		t.pushInst(qid, typeList)
+		instIdent, err = t.instantiateMethod(qid, method, mdecl, recvArgs, argList, typeList)
+		t.popInst()
+		if err != nil {
+			t.err = err
+			return
+		}
+		t.instantiations[key] = append(instantiations, &instantiation{
+			types: typeList,
+			decl:  instIdent,
+		})
+	}
+
+	// Take the address of, or indirect, the receiver as
+	// the method call would have done.
+	t.translateExpr(&sel.X)
+	x := sel.X
+	_, ptrMethod := mdecl.Recv.List[0].Type.(*ast.StarExpr)
+	switch {
+	case ptrMethod && !recvPtr:
+		x = &ast.UnaryExpr{OpPos: x.Pos(), Op: token.AND, X: x}
+		t.setType(x, types.NewPointer(xtyp))
+	case !ptrMethod && recvPtr:
+		x = &ast.StarExpr{Star: x.Pos(), X: x}
+		t.setType(x, xtyp)
+	}
+
+	newCall := &ast.CallExpr{
+		Fun:      instIdent,
+		Lparen:   call.Lparen,
+		Args:     append([]ast.Expr{x}, call.Args...),
+		Ellipsis: call.Ellipsis,
+		Rparen:   call.Rparen,
+	}
+	if typ := t.lookupType(call); typ != nil {
+		t.setType(newCall, typ)
+	}
+	*pe = newCall
+}
+
+// addTypeArgComment records a comment following call that shows
+// the function being called and its type arguments.
+func (t *translator) addTypeArgComment(call *ast.CallExpr, typeList []types.Type) {
+	var buf bytes.Buffer
+	buf.WriteString("/* ")
+	printer.Fprint(&buf, t.fset, call.Fun)
+	buf.WriteByte('(')
+	for i, typ := range typeList {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		types.WriteType(&buf, typ, types.RelativeTo(t.tpkg))
+	}
+	buf.WriteString(") */")
+	t.comments = append(t.comments, &ast.CommentGroup{
+		List: []*ast.Comment{
+			{
+				Slash: call.End(),
+				Text:  buf.String(),
+			},
+		},
+	})
+}
+
 // translateTypeInstantiation translates an instantiated type to Go 1.
 func (t *translator) translateTypeInstantiation(pe *ast.Expr) {
 	call := (*pe).(*ast.CallExpr)
 	qid := t.instantiatedIdent(call)
+	if qid.pkg != nil {
+		if obj := t.findTypesObject(qid); obj != nil {
+			if _, ok := t.importer.lookupTypeSpec(obj); !ok {
+				t.err = t.noSourceError(call, qid)
+				return
+			}
+		}
+	}
 	typ := t.lookupType(call.Fun).(*types.Named)
 	argList, typeList, typeArgs := t.instantiationTypes(call)
 	if !typeArgs {
 		panic("no type arguments for type")
 	}
+	if err := t.typeArgCountError(call, qid, typeList, typ.TParams()); err != nil {
+		t.err = err
+		return
+	}
+
+	// Refer to an instantiation declared by the package that
+	// declares the generic type, so that we use the same type.
+	if qid.pkg != nil && t.importer.isExportedInstantiation(t.findTypesObject(qid), typeList) {
+		// The generic type may be named without qualification,
+		// in code copied from its package or in code that dot
+		// imports it, so qualify it by its package's import name.
+		path := t.importer.importPath(qid.pkg)
+		name, ok := t.pkgNames[path]
+		if !ok {
+			panic(fmt.Sprintf("%s: package %q of %s is not imported", t.fset.Position(call.Pos()), path, qid))
+		}
+		*pe = &ast.SelectorExpr{
+			X:   &ast.Ident{NamePos: call.Fun.Pos(), Name: name},
+			Sel: ast.NewIdent(exportedInstantiatedName(qid.ident.Name, typeList, t.qualifier())),
+		}
+		return
+	}
 
 	instantiations := t.typeInstantiations[typ]
 	for _, inst := range instantiations {
 		if t.sameTypes(typeList, inst.types) {
+			t.importer.stats.TypeHits++
 			*pe = inst.decl
 			return
 		}
 	}
 
+	t.importer.stats.TypeMisses++
+	if err := t.instDepthError(call, qid); err != nil {
+		t.err = err
+		return
+	}
+	t.pushInst(qid, typeList)
 	instIdent, instType, err := t.instantiateTypeDecl(qid, typ, argList, typeList)
+	t.popInst()
 	if err != nil {
 		t.err = err
 		return
@@ -684,11 +1484,25 @@ func (t *translator) translateTypeInstantiation(pe *ast.Expr) {
 	*pe = instIdent
 }
 
+// noSourceError returns the error for an instantiation of qid,
+// a generic function or type declared in an imported package for
+// which we have no source code. We can only instantiate generic
+// code from packages that we translated from .go2 files.
+func (t *translator) noSourceError(call *ast.CallExpr, qid qualifiedIdent) error {
+	return fmt.Errorf("%s: cannot instantiate %s: package %q was not translated from .go2 source, and generic code from other packages can only be instantiated from source", t.fset.Position(call.Pos()), qid, qid.pkg.Path())
+}
+
 // instantiatedIdent returns the qualified identifer that is being
 // instantiated.
 func (t *translator) instantiatedIdent(call *ast.CallExpr) qualifiedIdent {
 	switch fun := call.Fun.(type) {
 	case *ast.Ident:
+		// A generic function of another package is named
+		// without qualification in code copied from that
+		// package, and in code that dot imports it.
+		if obj := t.importer.info.Uses[fun]; obj != nil && obj.Pkg() != nil && obj.Pkg() != t.tpkg {
+			return qualifiedIdent{pkg: obj.Pkg(), ident: fun}
+		}
 		return qualifiedIdent{ident: fun}
 	case *ast.SelectorExpr:
 		pkgname, ok := fun.X.(*ast.Ident)
@@ -726,30 +1540,38 @@ func (t *translator) instantiationTypes(call *ast.CallExpr) (argList []ast.Expr,
 		}
 		typeArgs = true
 	} else {
-		for _, typ := range inferred.Targs {
-			arg := ast.NewIdent(typ.String())
-			if named, ok := typ.(*types.Named); ok {
-				if len(named.TArgs()) > 0 {
-					var narg *ast.Ident
-					typ, narg = t.lookupInstantiatedType(named)
-					if narg != nil {
-						arg = ast.NewIdent(narg.Name)
-					}
+		argList, typeList = t.typeArgExprs(inferred.Targs)
+	}
+
+	return
+}
+
+// typeArgExprs returns AST expressions for the type arguments targs,
+// which are not written in the source, along with the types that
+// they denote.
+func (t *translator) typeArgExprs(targs []types.Type) (argList []ast.Expr, typeList []types.Type) {
+	for _, typ := range targs {
+		arg := ast.NewIdent(typ.String())
+		if named, ok := typ.(*types.Named); ok {
+			if len(named.TArgs()) > 0 {
+				var narg *ast.Ident
+				typ, narg = t.lookupInstantiatedType(named)
+				if narg != nil {
+					arg = ast.NewIdent(narg.Name)
 				}
-				if named.Obj().Pkg() == t.tpkg {
-					fields := strings.Split(arg.Name, ".")
-					if len(fields) > 1 {
-						arg = ast.NewIdent(fields[1])
-					}
+			}
+			if named.Obj().Pkg() == t.tpkg {
+				fields := strings.Split(arg.Name, ".")
+				if len(fields) > 1 {
+					arg = ast.NewIdent(fields[1])
 				}
 			}
-			typeList = append(typeList, typ)
-			argList = append(argList, arg)
-			t.setType(arg, typ)
 		}
+		typeList = append(typeList, typ)
+		argList = append(argList, arg)
+		t.setType(arg, typ)
 	}
-
-	return
+	return argList, typeList
 }
 
 // lookupInstantiatedType looks for an existing instantiation of an
@@ -810,6 +1632,17 @@ type qualifiedIdent struct {
 	ident *ast.Ident
 }
 
+// instKey returns the key under which the instantiations of the
+// generic function or method qid are recorded. Unlike String, it
+// qualifies the name by import path, as packages may have the same
+// path when they are type checked.
+func (t *translator) instKey(qid qualifiedIdent) string {
+	if qid.pkg == nil {
+		return qid.ident.Name
+	}
+	return t.importer.importPath(qid.pkg) + "." + qid.ident.Name
+}
+
 // String returns a printable name for qid.
 func (qid qualifiedIdent) String() string {
 	if qid.pkg == nil {
@@ -0,0 +1,1619 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"fmt"
+	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/parser"
+	"github.com/tdakkota/go2go/golib/token"
+	"github.com/tdakkota/go2go/golib/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rewriteTests are translated with RewriteBuffer. Each entry lists
+// strings that must appear in the output, and strings that must not.
+var rewriteTests = []struct {
+	name   string
+	opts   Options
+	src    string
+	want   []string
+	reject []string
+}{
+	{
+		name: "pointer type assertion",
+		src: `package p
+
+type List(type T) struct{ v T }
+
+func Get(type T)(x interface{}) (*List(T), bool) {
+	l, ok := x.(*List(T))
+	return l, ok
+}
+
+func F(x interface{}) int {
+	if l, ok := x.(*List(int)); ok {
+		return l.v
+	}
+	l, _ := Get(string)(x)
+	_ = l
+	return 0
+}
+`,
+		want: []string{
+			"x.(*instantiate୦୦List୦int)",
+			"x.(*instantiate୦୦List୦string)",
+			"type instantiate୦୦List୦int struct",
+		},
+		reject: []string{"List(int)", "List(T)"},
+	},
+	{
+		// By default the input directives are kept as comments,
+		// and the output directives refer to the .go2 file.
+		name: "line directives as comments",
+		src: `package p
+
+//line orig.y:100
+func Id(type T)(v T) T { return v }
+
+func F() int { return Id(1) }
+`,
+		want: []string{"//line orig.y:100", "//line line directives as comments.go2:4"},
+	},
+	{
+		name: "keep line directives",
+		opts: Options{KeepLineDirectives: true},
+		src: `package p
+
+//line orig.y:100
+func Id(type T)(v T) T { return v }
+
+func F() int { return Id(1) }
+`,
+		want:   []string{"//line orig.y:100", "//line orig.y:102"},
+		reject: []string{"keep line directives.go2:4"},
+	},
+	{
+		name: "indent with spaces",
+		opts: Options{Tabwidth: 4, IndentWithSpaces: true},
+		src: `package p
+
+func Id(type T)(v T) T {
+	if true {
+		return v
+	}
+	return v
+}
+
+func F() int { return Id(1) }
+`,
+		want:   []string{"\n    if true {\n        return v\n    }\n"},
+		reject: []string{"\t"},
+	},
+	{
+		name: "indent with tabs",
+		src: `package p
+
+func Id(type T)(v T) T {
+	if true {
+		return v
+	}
+	return v
+}
+
+func F() int { return Id(1) }
+`,
+		want: []string{"\n\tif true {\n\t\treturn v\n\t}\n"},
+	},
+	{
+		name: "unnamed results",
+		src: `package p
+
+func Two(type T)(x T) (T, bool) { return x, true }
+
+func Blank(type T)(x T) (_ T, ok bool) { return x, true }
+
+func F() {
+	a, b := Two(1)
+	c, d := Blank("s")
+	_, _, _, _ = a, b, c, d
+}
+`,
+		want: []string{
+			"func instantiate୦୦Two୦int(x int,) (int, bool)",
+			"func instantiate୦୦Blank୦string(x string,) (_ string, ok bool)",
+		},
+	},
+	{
+		name: "nested instantiation",
+		src: `package p
+
+type Box(type T) struct{ v T }
+
+func g(type T)(v T) Box(T) { return Box(T){v} }
+
+func f(type T)(v T) T { return g(v).v }
+
+func s(type T)(v T) []T { return g([]T{v}).v }
+
+func h(type T)(v T) T { return f(T)(v) }
+
+func F() (int, []string) { return h(1), s("s") }
+`,
+		want: []string{
+			"func instantiate୦୦h୦int(v int,) int",
+			"return instantiate୦୦f୦int(v)",
+			"return instantiate୦୦g୦int(v).v",
+			"func instantiate୦୦g୦int(v int,) instantiate୦୦Box୦int",
+			"return instantiate୦୦g୦୮6୮7string([]string{v}).v",
+			"type instantiate୦୦Box୦୮6୮7string struct{ v []string }",
+		},
+		reject: []string{"(T)", "[]T"},
+	},
+	{
+		name: "type argument comments",
+		opts: Options{TypeArgComments: true},
+		src: `package p
+
+func Map(type T, U)(s []T, f func(T) U) []U { return nil }
+
+func Id(type T)(v T) T { return Map([]T{v}, func(v T) T { return v })[0] }
+
+func F() {
+	_ = Map([]int{1}, func(int) string { return "" })
+	_ = Id(string)("s")
+}
+`,
+		want: []string{
+			`instantiate୦୦Map୦int୦string([]int{1}, func(int) string { return "" }) /* Map(int, string) */`,
+			`instantiate୦୦Id୦string("s")`,
+			`/* Id(string) */`,
+		},
+		reject: []string{"Map(string, string)"},
+	},
+	{
+		name: "embedded instantiation",
+		src: `package p
+
+type List(type T) struct{ v T }
+
+func (l *List(T)) Get() T { return l.v }
+
+type Outer(type T) struct {
+	(List(T))
+	n int
+}
+
+func Use(type T)(o *Outer(T)) T { return o.Get() }
+
+func F() int {
+	var o Outer(int)
+	o.v = 1
+	return Use(&o) + o.Get()
+}
+`,
+		want: []string{
+			"type instantiate୦୦Outer୦int struct {",
+			"\n instantiate୦୦List୦int\n",
+			"func instantiate୦୦Use୦int(o *instantiate୦୦Outer୦int,) int { return o.Get() }",
+			"func (l *instantiate୦୦List୦int,) Get() int",
+		},
+		reject: []string{"(instantiate୦୦List୦int)"},
+	},
+	{
+		name: "iota in generic function",
+		src: `package p
+
+type Kind int
+
+func Pick(type T)(v T) (T, Kind) {
+	const (
+		a Kind = iota
+		b
+		c
+	)
+	return v, b + c
+}
+
+func F() Kind { _, k := Pick("s"); return k }
+`,
+		want: []string{
+			"func instantiate୦୦Pick୦string(v string,) (string, Kind)",
+			"\tconst (\n\t\ta Kind = iota\n\t\tb\n\t\tc\n\t)\n",
+		},
+	},
+	{
+		name: "any",
+		src: `package p
+
+func Id(type T any)(v T) T { return v }
+
+func F() {
+	var a any = 1
+	_ = Id(&a)
+	_ = Id(any)(nil)
+	_ = []any{Id(1)}
+}
+`,
+		want: []string{
+			"var a interface{} = 1",
+			"func instantiate୦୦Id୦୮1interface୮4୮5(v *interface{}",
+			"func instantiate୦୦Id୦interface୮4୮5(v interface{}",
+			"[]interface{}{instantiate୦୦Id୦int(1)}",
+		},
+		reject: []string{"a any", "]any", "Id(any)"},
+	},
+	{
+		name: "interface conversion",
+		src: `package p
+
+type Stringer interface{ String() string }
+
+type S int
+
+func (S) String() string { return "" }
+
+func ToStringer(type T interface{ String() string })(v T) Stringer { return Stringer(v) }
+
+var _ = ToStringer(S(0))
+`,
+		want: []string{
+			"func instantiate୦୦ToStringer୦p୮aS(v S,) Stringer { return Stringer(v) }",
+		},
+	},
+	{
+		name: "interface parameter",
+		src: `package p
+
+type Writer interface{ Write(p []byte) (int, error) }
+
+type buf struct{ b []byte }
+
+func (b *buf) Write(p []byte) (int, error) { b.b = append(b.b, p...); return len(p), nil }
+
+func Fprint(type T any)(x T, w Writer) { _ = x; w.Write(nil) }
+
+func F() {
+	var b buf
+	Fprint(1, &b)
+	Fprint(string)("s", Writer(&b))
+}
+`,
+		want: []string{
+			"instantiate୦୦Fprint୦int(1, &b)",
+			`instantiate୦୦Fprint୦string("s", Writer(&b))`,
+			"func instantiate୦୦Fprint୦int(x int, w Writer)",
+			"func instantiate୦୦Fprint୦string(x string, w Writer)",
+		},
+	},
+	{
+		name: "constants in generic method",
+		src: `package p
+
+type List(type T) struct{ v []T }
+
+func (l *List(T)) Grow() int {
+	const (
+		min  = 4
+		step = min * 2
+	)
+	const n = len([3]T{})
+	var a [n]T
+	l.v = append(l.v, a[:]...)
+	return step
+}
+
+var _ = (&List(string){}).Grow()
+`,
+		want: []string{
+			"func (l *instantiate୦୦List୦string,) Grow() int",
+			"const n = len([3]string{})",
+			"var a [n]string",
+		},
+		reject: []string{"]T"},
+	},
+	{
+		name: "phantom type parameter",
+		src: `package p
+
+type Stringer interface{ String() string }
+
+func Convert(type From, To interface{ type int, int64 }, C Stringer)(x From) To {
+	return To(x)
+}
+
+type S struct{}
+
+func (S) String() string { return "" }
+
+var _ = Convert(int, int64, S)(1)
+`,
+		want: []string{
+			"func instantiate୦୦Convert୦int୦int64୦p୮aS(x int) int64",
+			"return int64(x)",
+		},
+		reject: []string{"From", "To", "C Stringer"},
+	},
+	{
+		name: "map key instantiation",
+		src: `package p
+
+type Pair(type K, V) struct {
+	k K
+	v V
+}
+
+contract pairKey(K, V) {
+	comparable(K)
+	comparable(V)
+}
+
+func Index(type K, V pairKey)(ks []K, vs []V) map[Pair(K, V)]bool {
+	m := make(map[Pair(K, V)]bool)
+	for i, k := range ks {
+		m[Pair(K, V){k, vs[i]}] = true
+	}
+	return m
+}
+
+var m = map[Pair(int, string)]bool{
+	{1, "a"}: true,
+}
+
+var _ = Index([]int{1}, []string{"a"})[Pair(int, string){1, "a"}]
+`,
+		want: []string{
+			"var m = map[instantiate୦୦Pair୦int୦string]bool{",
+			"map[instantiate୦୦Pair୦int୦string]bool {",
+			"m := make(map[instantiate୦୦Pair୦int୦string]bool)",
+			"m[instantiate୦୦Pair୦int୦string{k, vs[i]}] = true",
+			"type instantiate୦୦Pair୦int୦string struct",
+		},
+		reject: []string{"Pair(", "K, V"},
+	},
+	{
+		name: "pointer conversion",
+		src: `package p
+
+type List(type T) struct {
+	next *List(T)
+	val  T
+}
+
+type Other(type T) struct {
+	next *List(T)
+	val  T
+}
+
+func Convert(type T)(l *List(T)) *Other(T) {
+	return (*Other(T))(l)
+}
+
+var _ = Convert(&List(int){})
+var _ = (*Other(string))(&List(string){})
+`,
+		want: []string{
+			"return (*instantiate୦୦Other୦int)(l)",
+			"(*instantiate୦୦Other୦string)(&instantiate୦୦List୦string{})",
+			"type instantiate୦୦Other୦int struct",
+			"type instantiate୦୦Other୦string struct",
+		},
+		reject: []string{"(T)"},
+	},
+	{
+		name: "runtime interface",
+		src: `package p
+
+type Getter(type T) interface {
+	type int, float64
+	Get() T
+}
+
+type box struct{ v int }
+
+func (b box) Get() int { return b.v }
+
+var G Getter(int) = box{1}
+`,
+		want: []string{
+			"var G instantiate୦୦Getter୦int = box{1}",
+			"type instantiate୦୦Getter୦int interface",
+			"Get() int",
+		},
+		reject: []string{"type int, float64"},
+	},
+	{
+		name: "instantiated type conversion",
+		src: `package p
+
+type List(type T) []T
+
+type Pair(type K, V) struct {
+	k K
+	v V
+}
+
+type kv struct {
+	k int
+	v string
+}
+
+func ToList(type T)(s []T) List(T) {
+	l := List(T)(s)
+	return l
+}
+
+func F(y []int) int {
+	x := List(int)(y)
+	p := Pair(int, string)(kv{1, "a"})
+	return len(x) + len(ToList([]string{p.v}))
+}
+`,
+		want: []string{
+			"x := instantiate୦୦List୦int(y)",
+			"p := instantiate୦୦Pair୦int୦string(kv{1, \"a\"})",
+			"l := instantiate୦୦List୦string(s)",
+			"type instantiate୦୦List୦int []int",
+		},
+		reject: []string{"(T)", "(int)("},
+	},
+	{
+		name: "method type parameters",
+		src: `package p
+
+type List(type T) struct{ v []T }
+
+func (l *List(T)) Map(type U)(f func(T) U) []U {
+	var r []U
+	for _, v := range l.v {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func (l List(T)) Len() int { return len(l.v) }
+
+type Set struct{ m map[string]bool }
+
+func (s Set) Keys(type K)(conv func(string) K) []K {
+	var r []K
+	for k := range s.m {
+		r = append(r, conv(k))
+	}
+	return r
+}
+
+func F(l List(int), s *Set) []string {
+	_ = l.Map(func(i int) bool { return i > 0 })
+	_ = s.Keys(func(k string) int { return len(k) })
+	return l.Map(string)(func(i int) string { return "" })
+}
+`,
+		want: []string{
+			"_ = instantiate୦୦List୮aMap୦int୦bool(&l, func(i int) bool",
+			"return instantiate୦୦List୮aMap୦int୦string(&l, func(i int) string",
+			"_ = instantiate୦୦Set୮aKeys୦int(*s, func(k string) int",
+			"func instantiate୦୦List୮aMap୦int୦bool(l *instantiate୦୦List୦int, f func(int",
+			"func instantiate୦୦Set୮aKeys୦int(s Set, conv func(string",
+			"var r []string",
+			"func (l instantiate୦୦List୦int",
+			") Len() int { return len(l.v) }",
+		},
+		reject: []string{"(type U)", "(type K)", ") Map(", ") Keys("},
+	},
+	{
+		name: "anonymous structs",
+		src: `package p
+
+type Box(type T) struct{ inner struct{ v T } }
+
+func Pair(type T)(x, y T) T {
+	p := struct{ a, b T }{a: x, b: y}
+	var q struct {
+		v T
+		n *struct{ w []T }
+	}
+	q.v = p.a
+	s := []struct{ v T }{{x}, {v: y}}
+	f := func(s struct{ v T }) T { return s.v }
+	return f(s[0])
+}
+
+var A = Pair(1, 2)
+var B struct{ b Box(string) }
+`,
+		want: []string{
+			"p := struct{ a, b int }{a: x, b: y}",
+			"\t\tv int\n\t\tn *struct{ w []int }\n",
+			"s := []struct{ v int }{{x}, {v: y}}",
+			"f := func(s struct{ v int }) int { return s.v }",
+			"var B struct{ b instantiate୦୦Box୦string }",
+			"type instantiate୦୦Box୦string struct{ inner struct{ v string } }",
+		},
+		reject: []string{"struct{ v T }", "(type T)"},
+	},
+	{
+		name: "comments in function bodies",
+		src: `package p
+
+// Sum returns the sum of s.
+func Sum(type T interface{ type int, float64 })(s []T) T {
+	// Start from the zero value.
+	var r T
+	for _, v := range s {
+		r += v // add v
+	}
+	return r
+}
+
+type List(type T) []T
+
+// Len returns the length of l.
+func (l List(T)) Len() int {
+	return len(l) /* just the length */
+}
+
+// F uses Sum and List.
+func F(l List(int)) float64 {
+	_ = l.Len()
+	return Sum([]float64{1})
+}
+`,
+		want: []string{
+			"// F uses Sum and List.\nfunc F(",
+			"// Sum returns the sum of s.\nfunc instantiate୦୦Sum୦float64(",
+			"{\n\t// Start from the zero value.\n\tvar r float64\n",
+			"r += v // add v\n",
+			"// Len returns the length of l.\nfunc (l instantiate୦୦List୦int",
+			"return len(l) /* just the length */\n",
+		},
+		reject: []string{"Sum(type T", "List(type T"},
+	},
+	{
+		name: "new with type parameters",
+		src: `package p
+
+type Pair(type T) struct{ a, b T }
+
+func Ptr(type T)(v T) *T {
+	p := new(T)
+	*p = v
+	q := new(Pair(T))
+	q.a = *p
+	return &q.a
+}
+
+var A = Ptr(1)
+var B = Ptr("x")
+`,
+		want: []string{
+			"p := new(int)",
+			"p := new(string)",
+			"q := new(instantiate୦୦Pair୦int)",
+			"q := new(instantiate୦୦Pair୦string)",
+		},
+		reject: []string{"new(T)", "new(Pair(T))"},
+	},
+	{
+		name: "make with type parameters",
+		src: `package p
+
+func Make(type K comparable, V interface{})(n int) ([]V, map[K]V, chan<- map[K][]V) {
+	s := make([]V, 0, n)
+	m := make(map[K]V, n)
+	c := make(chan<- map[K][]V)
+	return s, m, c
+}
+
+func Grid(type T)(n int) [][]T {
+	g := make([][]T, n)
+	for i := range g {
+		g[i] = make([]T, n)
+	}
+	return g
+}
+
+var S, M, C = Make(string, float64)(1)
+var G = Grid(bool)(2)
+`,
+		want: []string{
+			"s := make([]float64, 0, n)",
+			"m := make(map[string]float64, n)",
+			"c := make(chan<- map[string][]float64)",
+			"g := make([][]bool, n)",
+			"g[i] = make([]bool, n)",
+		},
+		reject: []string{"make([]V", "map[K]V", "make([]T", "make([][]T"},
+	},
+	{
+		name: "arrays of type parameters",
+		src: `package p
+
+const k = 2
+
+type Ring(type T) struct {
+	buf [k * 2]T
+	n   int
+}
+
+func Len(type A)(a A) int { return 0 }
+
+func Fill(type T)(v T) [3]T {
+	var a [3]T
+	for i := range a {
+		a[i] = v
+	}
+	_ = Len(a)
+	var r Ring(T)
+	r.buf[0] = a[0]
+	return a
+}
+
+var A = Fill(1.5)
+`,
+		want: []string{
+			"func instantiate୦୦Fill୦float64(v float64,) [3]float64",
+			"var a [3]float64",
+			"_ = instantiate୦୦Len୦୮63୮7float64(a)",
+			"var r instantiate୦୦Ring୦float64",
+			"buf [k * 2]float64",
+		},
+		reject: []string{"[3]T", "]T\n", "୮7T"},
+	},
+	{
+		name: "init functions",
+		src: `package p
+
+type Set(type T comparable) map[T]bool
+
+func Add(type T comparable)(s Set(T), v T) { s[v] = true }
+
+var names Set(string)
+
+func init() {
+	names = make(Set(string))
+	Add(names, "a")
+}
+
+func init() {
+	Add(Set(int){}, 1)
+}
+`,
+		want: []string{
+			"names = make(instantiate୦୦Set୦string)",
+			"instantiate୦୦Add୦string(names, \"a\")",
+			"instantiate୦୦Add୦int(instantiate୦୦Set୦int{}, 1)",
+			"func instantiate୦୦Add୦string(s instantiate୦୦Set୦string, v string,)",
+			"func instantiate୦୦Add୦int(s instantiate୦୦Set୦int, v int,)",
+			"type instantiate୦୦Set୦string map[string]bool",
+			"type instantiate୦୦Set୦int map[int]bool",
+		},
+		reject: []string{"Add(names", "Set(string)", "Set(int)"},
+	},
+	{
+		name: "recursive instantiation",
+		src: `package p
+
+func Swap(type A, B)(n int, a A, b B) int {
+	if n == 0 {
+		return 0
+	}
+	return Swap(n-1, b, a)
+}
+
+var X = Swap(3, 1, "x")
+`,
+		want: []string{
+			"var X = instantiate୦୦Swap୦int୦string(3, 1, \"x\")",
+			"func instantiate୦୦Swap୦int୦string(n int, a int, b string,) int",
+			"return instantiate୦୦Swap୦string୦int(n-1, b, a)",
+			"func instantiate୦୦Swap୦string୦int(n int, a string, b int,) int",
+			"return instantiate୦୦Swap୦int୦string(n-1, b, a)",
+		},
+		reject: []string{"Swap୦int୦int", "Swap୦string୦string"},
+	},
+	{
+		name: "comparable map keys",
+		src: `package p
+
+type Table(type K comparable, V interface{}) struct{ m map[K]V }
+
+func NewTable(type K comparable, V interface{})() Table(K, V) {
+	return Table(K, V){m: make(map[K]V)}
+}
+
+type key struct{ a, b int }
+
+var T = NewTable(key, []string)()
+`,
+		want: []string{
+			"type instantiate୦୦Table୦p୮akey୦୮6୮7string struct{ m map[key][]string }",
+			"return instantiate୦୦Table୦p୮akey୦୮6୮7string{m: make(map[key][]string)}",
+		},
+		reject: []string{"map[K]V"},
+	},
+	{
+		name: "guarded type assertions",
+		src: `package p
+
+func Get(type T)(x interface{}) T {
+	return x.(T)
+}
+
+func Try(type T)(x interface{}) (T, bool) {
+	v, ok := x.(T)
+	switch x.(type) {
+	case string:
+		_ = x.(string)
+	}
+	return v, ok
+}
+
+var A = Get(int)(1)
+var B, C = Try(string)("c")
+`,
+		opts: Options{GuardTypeAssertions: true},
+		want: []string{
+			"return func(x୦ interface",
+			"v୦, ok୦ := x୦.(int)",
+			"if !ok୦ {",
+			`panic("go2go: type assertion x.(T) failed in Get(int)")`,
+			"return v୦",
+			"}(x)",
+			"v, ok := x.(string)",
+			"switch x.(type) {",
+			"_ = x.(string)",
+		},
+		reject: []string{"in Try(string)"},
+	},
+	{
+		name: "embedded type parameter",
+		src: `package p
+
+type Getter interface {
+	Get() int
+}
+
+type Box(type T Getter) struct {
+	T
+	n int
+}
+
+func (b *Box(T)) Twice() int { return b.Get() + b.T.Get() }
+
+type intGetter int
+
+func (g intGetter) Get() int { return int(g) }
+
+var B = Box(intGetter){T: intGetter(1)}
+var X = B.Get()
+var Y = B.T
+var Z = B.Twice()
+`,
+		want: []string{
+			"type instantiate୦୦Box୦p୮aintGetter struct {",
+			" intGetter\n",
+			"return b.Get() + b.intGetter.Get()",
+			"{intGetter: intGetter(1)}",
+			"var X = B.Get()",
+			"var Y = B.intGetter",
+		},
+	},
+	{
+		name: "embedded generic interface",
+		src: `package p
+
+type Getter(type T) interface {
+	Get() T
+}
+
+type Box(type T) struct {
+	(Getter(T))
+}
+
+func (b Box(T)) Both() (T, T) { return b.Get(), b.Getter.Get() }
+
+type intGetter int
+
+func (g intGetter) Get() int { return int(g) }
+
+var B = Box(int){Getter: intGetter(1)}
+var X = B.Get()
+var Y, Z = B.Both()
+`,
+		want: []string{
+			" instantiate୦୦Getter୦int\n",
+			"b.Get(), b.instantiate୦୦Getter୦int.Get()",
+			"{instantiate୦୦Getter୦int: intGetter(1)}",
+			"var X = B.Get()",
+		},
+	},
+	{
+		name: "slice to array pointer conversions",
+		src: `package p
+
+func First4(type T)(s []T) *[4]T {
+	return (*[4]T)(s)
+}
+
+var A = First4([]int{1, 2, 3, 4, 5})
+`,
+		want: []string{
+			"func instantiate୦୦First4୦int(s []int,) *[4]int {",
+			"return (*[4]int)(s)",
+		},
+	},
+	{
+		name: "returned closures",
+		src: `package p
+
+func Adder(type T interface{ type int, float64 })(x T) func(T) T {
+	return func(y T) T {
+		var z T = x + y
+		inner := func() []T { return []T{z} }
+		return inner()[0]
+	}
+}
+
+func Pair(type T)(x T) func() (T, func(T) []T) {
+	return func() (T, func(T) []T) {
+		return x, func(y T) []T { return []T{x, y} }
+	}
+}
+
+var F = Adder(1)
+var A = F(2)
+var B = Adder(1.5)(2)
+var C, G = Pair("a")()
+var D = G("b")
+`,
+		want: []string{
+			"var F = instantiate୦୦Adder୦int(1)",
+			"var A = F(2)",
+			"var B = instantiate୦୦Adder୦float64(1.5)(2)",
+			"func instantiate୦୦Adder୦int(x int,) func(int,) int {",
+			"return func(y int,) int {",
+			"var z int = x + y",
+			"inner := func() []int { return []int{z} }",
+			"var z float64 = x + y",
+			"var C, G = instantiate୦୦Pair୦string(\"a\")()",
+			"return func() (string, func(string,) []string,) {",
+			"return x, func(y string,) []string { return []string{x, y} }",
+		},
+		reject: []string{"T)", "[]T"},
+	},
+	{
+		name: "struct tags",
+		src: "package p\n\n" +
+			"type Pair(type K, V) struct {\n" +
+			"\tKey   K `json:\"key\"`\n" +
+			"\tValue V `json:\"value,omitempty\" xml:\"v\"`\n" +
+			"\tn     int\n" +
+			"}\n\n" +
+			"func Wrap(type T)(x T) interface{} {\n" +
+			"\treturn struct {\n" +
+			"\t\tV T `json:\"v\"`\n" +
+			"\t}{x}\n" +
+			"}\n\n" +
+			"var P Pair(int, string)\n" +
+			"var Q Pair(struct {\n" +
+			"\tA int `json:\"a\"`\n" +
+			"}, bool)\n" +
+			"var W = Wrap(1)\n",
+		want: []string{
+			"type instantiate୦୦Pair୦int୦string struct {",
+			"Key   int    `json:\"key\"`",
+			"Value string `json:\"value,omitempty\" xml:\"v\"`",
+			"A int `json:\"a\"`",
+			"} `json:\"key\"`",
+			"Value bool `json:\"value,omitempty\" xml:\"v\"`",
+			"V int `json:\"v\"`",
+		},
+	},
+	{
+		name: "typed iota constants",
+		src: `package p
+
+type Enum(type T) int
+
+func (e Enum(T)) Next() Enum(T) { return e + 1 }
+
+const (
+	A Enum(string) = iota
+	B
+	C
+)
+
+func Last(type T)() Enum(T) {
+	const (
+		x Enum(T) = iota * 2
+		y
+	)
+	return y
+}
+
+var N = B.Next()
+var L = Last(bool)()
+`,
+		want: []string{
+			"A instantiate୦୦Enum୦string = iota",
+			"type instantiate୦୦Enum୦string int",
+			"func (e instantiate୦୦Enum୦string,) Next() instantiate୦୦Enum୦string {",
+			"func instantiate୦୦Last୦bool() instantiate୦୦Enum୦bool {",
+			"x instantiate୦୦Enum୦bool = iota * 2",
+		},
+		reject: []string{"Enum(T)", "Enum(string)"},
+	},
+	{
+		name: "generic interface embedded in a constraint",
+		src: `package p
+
+type Getter(type T) interface{ Get() T }
+
+func Read(type T interface{}, G interface{ (Getter(T)); Reset() })(g G) T {
+	g.Reset()
+	return g.Get()
+}
+
+type Counter struct{ n int }
+
+func (c *Counter) Get() int { c.n++; return c.n }
+func (c *Counter) Reset()   { c.n = 0 }
+
+var N = Read(int, *Counter)(&Counter{})
+`,
+		want: []string{
+			"var N = instantiate୦୦Read୦int୦୮1p୮aCounter(&Counter{})",
+			"func instantiate୦୦Read୦int୦୮1p୮aCounter(g *Counter) int {",
+			"return g.Get()",
+		},
+		reject: []string{"Getter(T)", "Getter(int)"},
+	},
+	{
+		name: "method values in composite literals",
+		src: `package p
+
+type Stack(type T) struct{ elems []T }
+
+func (s *Stack(T)) Push(v T) { s.elems = append(s.elems, v) }
+func (s *Stack(T)) Len() int { return len(s.elems) }
+
+func Pushers(type T)(s *Stack(T)) []func(T) {
+	push := (*Stack(T)).Push
+	return []func(T){s.Push, func(v T) { push(s, v) }}
+}
+
+var s Stack(int)
+var F = []func(int){s.Push}
+var L = map[string]func() int{"len": s.Len}
+var M = []func(*Stack(string), string){(*Stack(string)).Push}
+var P = Pushers(&s)
+`,
+		want: []string{
+			"var s instantiate୦୦Stack୦int",
+			"var F = []func(int){s.Push}",
+			"var L = map[string]func() int{\"len\": s.Len}",
+			"var M = []func(*instantiate୦୦Stack୦string, string){(*instantiate୦୦Stack୦string).Push}",
+			"func (s *instantiate୦୦Stack୦int,) Push(v int) { s.elems = append(s.elems, v) }",
+			"func instantiate୦୦Pushers୦int(s *instantiate୦୦Stack୦int,) []func(int,) {",
+			"push := (*instantiate୦୦Stack୦int).Push",
+			"return []func(int,){s.Push, func(v int,) { push(s, v) }}",
+		},
+		reject: []string{"Stack(T)", "Stack(int)", "Stack(string)"},
+	},
+	{
+		name: "address of type parameter field",
+		src: `package p
+
+type Box(type T) struct {
+	v    T
+	list []T
+}
+
+func (b *Box(T)) Ptr() *T { return &b.v }
+
+func Fields(type T)(b *Box(T)) (*T, *[]T, *T) {
+	p := &b.v
+	var q *[]T = &b.list
+	r := &(*b).v
+	return p, q, r
+}
+
+func Set(type T)(p *T, v T) { *p = v }
+
+var b Box(string)
+var P = b.Ptr()
+var X, Y, Z = Fields(&b)
+
+func init() { Set(&b.v, "x") }
+`,
+		want: []string{
+			"func (b *instantiate୦୦Box୦string,) Ptr() *string {",
+			"return &b.v",
+			"func instantiate୦୦Fields୦string(b *instantiate୦୦Box୦string,) (*string, *[]string, *string,) {",
+			"p := &b.v",
+			"var q *[]string = &b.list",
+			"r := &(*b).v",
+			"func init() { instantiate୦୦Set୦string(&b.v, \"x\") }",
+			"func instantiate୦୦Set୦string(p *string, v string,) { *p = v }",
+		},
+		reject: []string{"*T", "[]T", "Box(string)"},
+	},
+	{
+		name: "index instantiation",
+		src: `package p
+
+func Id(type T)(x T) T { return x }
+
+type List(type T) []T
+
+func Wrap(type T)(x T) List(T) { return List[T]{Id[T](x)} }
+
+var (
+	a = Id[int](1)
+	f = Id[[]int]
+	l = List[string](nil)
+	w = Wrap[int](1)
+)
+`,
+		want: []string{
+			"a = instantiate୦୦Id୦int(1)",
+			"f = instantiate୦୦Id୦୮6୮7int",
+			"l = instantiate୦୦List୦string(nil)",
+			"return instantiate୦୦List୦int{instantiate୦୦Id୦int(x)}",
+		},
+		reject: []string{"Id[", "List["},
+	},
+	{
+		name: "index instantiation with several type arguments",
+		src: `package p
+
+func Second(type T, U)(x T, y U) U { return y }
+
+type Pair(type K comparable, V interface{}) struct {
+	k K
+	v V
+}
+
+func Swap(type K comparable, V comparable)(p Pair(K, V)) Pair(V, K) {
+	return Pair[V, K]{p.v, p.k}
+}
+
+var (
+	b = Second[int, string](1, "")
+	p = Pair[string, float64]{"a", 1}
+	s = Swap[string, int](Pair[string, int]{})
+)
+`,
+		want: []string{
+			`b = instantiate୦୦Second୦int୦string(1, "")`,
+			`p = instantiate୦୦Pair୦string୦float64{"a", 1}`,
+			"s = instantiate୦୦Swap୦string୦int(instantiate୦୦Pair୦string୦int{})",
+			"return instantiate୦୦Pair୦int୦string{p.v, p.k}",
+		},
+		reject: []string{"[V, K]", "[string, int]"},
+	},
+}
+
+func TestRewriteBuffer(t *testing.T) {
+	for _, test := range rewriteTests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			tmpdir := tempDir(t)
+			defer os.RemoveAll(tmpdir)
+			imp := NewImporter(tmpdir)
+			imp.SetOptions(test.opts)
+			out, err := RewriteBuffer(imp, test.name+".go2", []byte(test.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := string(out)
+			for _, want := range test.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output does not contain %q:\n%s", want, got)
+				}
+			}
+			for _, reject := range test.reject {
+				if strings.Contains(got, reject) {
+					t.Errorf("output unexpectedly contains %q:\n%s", reject, got)
+				}
+			}
+			if _, err := parser.ParseFile(token.NewFileSet(), "out.go", out, 0); err != nil {
+				t.Errorf("output does not parse: %v\n%s", err, got)
+			}
+		})
+	}
+}
+
+func TestRewriteGenerateDirective(t *testing.T) {
+	const directive = "//go:generate go2go -tabs=false translate p.go2"
+	for _, src := range []string{
+		"package p\n\nfunc Id(type T)(v T) T { return v }\n\nvar X = Id(1)\n",
+		"package p\nvar X int",
+		"package p",
+	} {
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		imp := NewImporter(tmpdir)
+		imp.SetOptions(Options{GenerateCommand: "go2go -tabs=false translate"})
+		out, err := RewriteBuffer(imp, "p.go2", []byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(string(out), "\n")
+		pkgLine, dirLine := -1, -1
+		for i, line := range lines {
+			if strings.HasPrefix(line, "package ") && pkgLine < 0 {
+				pkgLine = i
+			}
+			if strings.Contains(line, "go:generate") {
+				if line != directive {
+					t.Errorf("%q: got directive line %q, want %q", src, line, directive)
+				}
+				dirLine = i
+			}
+		}
+		if dirLine < 0 || dirLine < pkgLine {
+			t.Errorf("%q: directive not found after package clause:\n%s", src, out)
+		}
+	}
+}
+
+func TestRewriteNoSource(t *testing.T) {
+	// Type check package a directly, so that the importer
+	// knows about it but has no source for its generic code.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", `package a; func Id(type T)(v T) T { return v }; type Box(type T) struct{ v T }`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf types.Config
+	apkg, err := conf.Check("a", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{
+		`package p; import "./a"; func F() int { return a.Id(1) }`,
+		`package p; import "./a"; var B a.Box(int)`,
+	} {
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		imp := NewImporter(tmpdir)
+		if err := imp.Register("a", []*types.Package{apkg}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := RewriteBuffer(imp, "p.go2", []byte(src))
+		if err == nil {
+			t.Errorf("%s: RewriteBuffer succeeded unexpectedly", src)
+			continue
+		}
+		if !strings.Contains(err.Error(), `package "a" was not translated from .go2 source`) {
+			t.Errorf("%s: unexpected error: %v", src, err)
+		}
+	}
+}
+
+func TestRewriteTypeParamConst(t *testing.T) {
+	// Constants cannot depend on type parameters; the translator
+	// must report the type error rather than emit a const declaration.
+	src := `package p; func F(type T interface{ type int })() int { const c = int(T(0)); return c }`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	_, err := RewriteBuffer(NewImporter(tmpdir), "p.go2", []byte(src))
+	if err == nil {
+		t.Fatal("RewriteBuffer succeeded unexpectedly")
+	}
+	if !strings.Contains(err.Error(), "value depends on type parameter T") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRewriteAliasedImport(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	adir := filepath.Join(go2path, "src", "example.com", "a")
+	if err := os.MkdirAll(adir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, adir, map[string]string{
+		"a.go2": `package a; type Box(type T) struct{ V T }; func Id(type T)(v T) T { return v }`,
+	})
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+
+	src := `package p
+
+import foo "example.com/a"
+
+var X = foo.Id(1)
+
+var B foo.Box(string)
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	out, err := RewriteBuffer(NewImporter(tmpdir), "p.go2", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		`foo "example.com/a"`,
+		"var X = instantiate୦a୦Id୦int(1)",
+		"var B instantiate୦a୦Box୦string",
+		"func instantiate୦a୦Id୦int(v int,) int",
+		"type instantiate୦a୦Box୦string struct",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "instantiate୦foo") {
+		t.Errorf("output names instantiations after the import alias:\n%s", got)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "out.go", out, 0); err != nil {
+		t.Errorf("output does not parse: %v\n%s", err, got)
+	}
+}
+
+func TestRewriteImportCollision(t *testing.T) {
+	go2path := tempDir(t)
+	defer os.RemoveAll(go2path)
+	for dir, src := range map[string]string{
+		"x/util": `package util; func F() int { return 1 }`,
+		"y/util": `package util; func G() int { return 2 }`,
+		"a":      `package a; import "example.com/x/util"; func Call(type T)(v T) int { return util.F() }`,
+	} {
+		pdir := filepath.Join(go2path, "src", "example.com", dir)
+		if err := os.MkdirAll(pdir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeFiles(t, pdir, map[string]string{filepath.Base(dir) + ".go2": src})
+	}
+	defer os.Setenv("GO2PATH", os.Getenv("GO2PATH"))
+	os.Setenv("GO2PATH", go2path)
+
+	// Both util packages are imported by the output; the one
+	// that is not imported by the source gets an alias.
+	src := `package p
+
+import (
+	"example.com/a"
+	"example.com/y/util"
+)
+
+var X = a.Call(1) + util.G()
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	out, err := RewriteBuffer(NewImporter(tmpdir), "p.go2", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		`util2 "example.com/x/util"`,
+		`"example.com/y/util"`,
+		"util.G()",
+		"return util2.",
+		"type _ util2.Importable୦",
+		"type _ util.Importable୦",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "util3") {
+		t.Errorf("output has more aliases than needed:\n%s", got)
+	}
+}
+
+func TestRewritePanicContext(t *testing.T) {
+	// Simulate a translator bug in the instantiation of Inner.
+	testHookInstantiate = func(name string) {
+		if name == "Inner" {
+			panic("simulated bug")
+		}
+	}
+	defer func() { testHookInstantiate = nil }()
+
+	src := `package p
+
+func Inner(type T)(v T) []T { return []T{v} }
+
+func Outer(type T)(v T) int {
+	_ = Inner([]T{v})
+	return 0
+}
+
+var _ = Outer(1)
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	_, err := RewriteBuffer(NewImporter(tmpdir), "p.go2", []byte(src))
+	if err == nil {
+		t.Fatal("RewriteBuffer succeeded unexpectedly")
+	}
+	want := "internal error: simulated bug\n\twhile instantiating Inner([]int)\n\twhile instantiating Outer(int)"
+	if msg := err.Error(); msg != want {
+		t.Errorf("got error %q, want %q", msg, want)
+	}
+}
+
+func TestRewriteIndexInstantiationType(t *testing.T) {
+	// Index syntax instantiates a generic type only where the type
+	// is written as an expression, as in List[int]{}. Elsewhere the
+	// parser does not accept it, and call syntax is needed.
+	for _, src := range []string{
+		`package p; type L(type T) []T; var _ L[int]`,
+		`package p; type L(type T) []T; func F(x L[int]) {}`,
+	} {
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		_, err := RewriteBuffer(NewImporter(tmpdir), "p.go2", []byte(src))
+		if err == nil {
+			t.Errorf("%s: RewriteBuffer succeeded unexpectedly", src)
+		}
+	}
+}
+
+func TestRewriteOnGenerate(t *testing.T) {
+	src := `package p
+
+func Id(type T)(v T) T { return v }
+
+type Box(type T) struct{ v T }
+
+func (b Box(T)) Get() T { return Id(b.v) }
+
+func Unbox(type T)(b Box(T)) T { return b.Get() }
+
+var (
+	_ = Id(1)
+	_ = Id(2)
+	_ = Id("s")
+	_ = Unbox(Box(int){1})
+	_ = Box(int){2}
+)
+`
+	var got []string
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	imp.SetOptions(Options{
+		OnGenerate: func(decl ast.Decl, orig types.Object, targs []types.Type) {
+			var name string
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				name = decl.Name.Name
+			case *ast.GenDecl:
+				name = decl.Specs[0].(*ast.TypeSpec).Name.Name
+			}
+			got = append(got, fmt.Sprintf("%s%v %T %s", orig.Name(), targs, decl, name))
+		},
+	})
+	if _, err := RewriteBuffer(imp, "p.go2", []byte(src)); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Id[int] *ast.FuncDecl instantiate୦୦Id୦int",
+		"Id[string] *ast.FuncDecl instantiate୦୦Id୦string",
+		"Box[int] *ast.GenDecl instantiate୦୦Box୦int",
+		"Unbox[int] *ast.FuncDecl instantiate୦୦Unbox୦int",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(want, "\n\t"))
+	}
+}
+
+func TestRewriteStats(t *testing.T) {
+	src := `package p
+
+func Id(type T)(v T) T { return v }
+
+type Box(type T) struct{ v T }
+
+var (
+	_ = Id(1)
+	_ = Id(2)
+	_ = Id(3)
+	_ Box(string)
+	_ = Box(string){"s"}
+)
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	if _, err := RewriteBuffer(imp, "p.go2", []byte(src)); err != nil {
+		t.Fatal(err)
+	}
+	want := Stats{FuncHits: 2, FuncMisses: 1, TypeHits: 1, TypeMisses: 1}
+	if got := imp.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNeedsTranslation(t *testing.T) {
+	for _, test := range []struct {
+		src  string
+		want bool
+	}{
+		{`package p; func F(x int) int { return x + 1 }`, false},
+		{`package p; type S struct{ v []int }; func (s *S) Len() int { return len(s.v) }`, false},
+		{`package p; type I interface{ M() }; var _ = I(nil)`, false},
+		{`package p; func F(type T)(v T) T { return v }`, true},
+		{`package p; type List(type T) []T`, true},
+		{`package p; type List(type T) []T; func (l List(T)) Len() int { return len(l) }`, true},
+		{`package p; contract C(T) { T int }`, true},
+		{`package p; var X any`, true},
+		{`package p; type Box(type T) struct{ v T }; func F(type T)(v T) T { return v }; var _ = F(1)`, true},
+		{`package p; func F() { type Num interface{ type int }; var _ Num }`, false},
+	} {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "p.go2", test.src, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", test.src, err)
+		}
+		info := &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Defs:  make(map[*ast.Ident]types.Object),
+			Uses:  make(map[*ast.Ident]types.Object),
+		}
+		var conf types.Config
+		if _, err := conf.Check("p", fset, []*ast.File{file}, info); err != nil {
+			t.Fatalf("%s: %v", test.src, err)
+		}
+		if got := needsTranslation(file, info); got != test.want {
+			t.Errorf("%s: needsTranslation = %t, want %t", test.src, got, test.want)
+		}
+	}
+}
+
+// nonGenericSource returns the source of a package with n
+// functions and types, none of which use generics.
+func nonGenericSource(n int) string {
+	var b strings.Builder
+	b.WriteString("package p\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `
+// T%[1]d is a type.
+type T%[1]d struct {
+	a, b int
+	m    map[string][]int
+	f    func(int) (int, error)
+}
+
+func (t *T%[1]d) Sum(xs ...int) (s int) {
+	for i, x := range xs {
+		switch {
+		case i%%2 == 0:
+			s += x * t.a
+		default:
+			s -= x / (t.b + 1)
+		}
+	}
+	if v, ok := t.m["k"]; ok && len(v) > 0 {
+		s += v[len(v)-1]
+	}
+	return s
+}
+
+func F%[1]d(c chan int) int {
+	t := &T%[1]d{a: %[1]d, m: map[string][]int{}}
+	defer func() { _ = recover() }()
+	select {
+	case v := <-c:
+		return t.Sum(v, 1, 2)
+	default:
+	}
+	return t.Sum([]int{1, 2, 3}[1:]...)
+}
+`, i)
+	}
+	return b.String()
+}
+
+func TestRewriteFastPath(t *testing.T) {
+	src := []byte(nonGenericSource(10))
+	rewrite := func(force bool) []byte {
+		defer func(old bool) { testForceTranslate = old }(testForceTranslate)
+		testForceTranslate = force
+		tmpdir := tempDir(t)
+		defer os.RemoveAll(tmpdir)
+		out, err := RewriteBuffer(NewImporter(tmpdir), "p.go2", src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	fast, full := rewrite(false), rewrite(true)
+	if string(fast) != string(full) {
+		t.Errorf("fast path output differs from full translation\nfast:\n%s\nfull:\n%s", fast, full)
+	}
+}
+
+func BenchmarkRewriteNonGeneric(b *testing.B) {
+	src := nonGenericSource(500)
+	tmpdir := tempDir(b)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go2", src, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	conf := types.Config{Importer: imp}
+	tpkg, err := conf.Check("p", fset, []*ast.File{file}, imp.info)
+	if err != nil {
+		b.Fatal(err)
+	}
+	imp.addIDs(file)
+
+	// A non-generic file is not changed by translation,
+	// so each iteration can rewrite the same AST.
+	for _, force := range []bool{false, true} {
+		name := "fast"
+		if force {
+			name = "full"
+		}
+		b.Run(name, func(b *testing.B) {
+			defer func(old bool) { testForceTranslate = old }(testForceTranslate)
+			testForceTranslate = force
+			for i := 0; i < b.N; i++ {
+				if err := rewriteAST(fset, imp, "", tpkg, file, newInstantiationCache(imp, tpkg), false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteLabels(t *testing.T) {
+	const src = `package p
+
+func Index(type T comparable)(s []T, x T) int {
+	i := 0
+loop:
+	for ; i < len(s); i++ {
+		if s[i] == x {
+			goto found
+		}
+		continue loop
+	}
+	return -1
+found:
+	return i
+}
+
+var A = Index([]int{1}, 1)
+var B = Index([]string{"a"}, "a")
+`
+	tmpdir := tempDir(t)
+	defer os.RemoveAll(tmpdir)
+	imp := NewImporter(tmpdir)
+	out, err := RewriteBuffer(imp, "p.go2", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, s := range []string{"\nloop:\n", "goto found\n", "continue loop\n", "\nfound:\n"} {
+		if n := strings.Count(got, s); n != 2 {
+			t.Errorf("output contains %q %d times, want 2:\n%s", s, n, got)
+		}
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", out, 0)
+	if err != nil {
+		t.Fatalf("output does not parse: %v\n%s", err, got)
+	}
+	if _, err := new(types.Config).Check("p", fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("output does not type check: %v\n%s", err, got)
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/token"
+	"path/filepath"
+	"strings"
+)
+
+// splitFileName returns the name of the n'th additional file
+// generated for filename when splitting declarations.
+// For foo.go2 this is foo.genN.go; for foo_test.go2 it is
+// foo.genN_test.go, so that the file is still a test file.
+func splitFileName(filename string, n int) string {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if strings.HasSuffix(base, "_test") {
+		return fmt.Sprintf("%s.gen%d_test.go", strings.TrimSuffix(base, "_test"), n)
+	}
+	return fmt.Sprintf("%s.gen%d.go", base, n)
+}
+
+// splitFile moves declarations created by instantiation out of file
+// if it would otherwise hold more of them than the SplitDecls option
+// allows, or be larger when printed than the SplitBytes option
+// allows. The declarations that fit stay in file, and the rest are
+// moved, in order, into as many additional files as are needed, which
+// it returns. Each additional file has the package clause and imports
+// of file, along with the references that keep the imports from being
+// unused, and at least one declaration, so a declaration too large for
+// SplitBytes gets a file of its own. A comment inside a declaration
+// that is moved is moved with it. Sizes are found by printing the rest
+// of file and each declaration once, so they may be off by a few
+// bytes where declarations meet.
+func splitFile(fset *token.FileSet, importer *Importer, file *ast.File) ([]*ast.File, error) {
+	newFile := func(decls []ast.Decl, comments []*ast.CommentGroup) *ast.File {
+		return &ast.File{
+			Package:  file.Package,
+			Name:     &ast.Ident{NamePos: file.Name.NamePos, Name: file.Name.Name},
+			Decls:    decls,
+			Comments: comments,
+		}
+	}
+	size := func(decls []ast.Decl, comments []*ast.CommentGroup) (int, error) {
+		var buf bytes.Buffer
+		if err := printGoFile(&buf, fset, importer, newFile(decls, comments)); err != nil {
+			return 0, err
+		}
+		return buf.Len(), nil
+	}
+
+	var header, insts, rest []ast.Decl
+	imports := importNames(file, importer.importNames[file])
+	for _, decl := range file.Decls {
+		switch {
+		case isInstantiatedDecl(decl):
+			insts = append(insts, decl)
+			continue
+		case isImportDecl(decl) || isImportReference(decl, imports):
+			header = append(header, decl)
+		}
+		rest = append(rest, decl)
+	}
+
+	// A comment belongs to the first instantiated declaration that
+	// contains it, if any.
+	owners := make(map[*ast.CommentGroup]ast.Decl)
+	comments := make(map[ast.Decl][]*ast.CommentGroup)
+	var restComments []*ast.CommentGroup
+	for _, cg := range file.Comments {
+		for _, decl := range insts {
+			if decl.Pos() <= cg.Pos() && cg.End() <= decl.End() {
+				owners[cg] = decl
+				comments[decl] = append(comments[decl], cg)
+				break
+			}
+		}
+		if owners[cg] == nil {
+			restComments = append(restComments, cg)
+		}
+	}
+
+	// Measure the rest of file, an additional file without any
+	// instantiated declarations, and each declaration.
+	maxBytes := importer.opts.SplitBytes
+	var restSize, headerSize int
+	sizes := make(map[ast.Decl]int)
+	if maxBytes > 0 {
+		var err error
+		if restSize, err = size(rest, restComments); err != nil {
+			return nil, err
+		}
+		if headerSize, err = size(header, nil); err != nil {
+			return nil, err
+		}
+		empty, err := size(nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range insts {
+			n, err := size([]ast.Decl{decl}, comments[decl])
+			if err != nil {
+				return nil, err
+			}
+			sizes[decl] = n - empty
+		}
+	}
+	fits := func(n, size int) bool {
+		if max := importer.opts.SplitDecls; max > 0 && n > max {
+			return false
+		}
+		return maxBytes <= 0 || size <= maxBytes
+	}
+
+	// Fill file first, and then the additional files.
+	keep := make(map[ast.Decl]bool)
+	var files []*ast.File
+	n, total := 0, restSize
+	for _, decl := range insts {
+		if files == nil {
+			if fits(n+1, total+sizes[decl]) {
+				keep[decl] = true
+				n++
+				total += sizes[decl]
+				continue
+			}
+		}
+		if files == nil || !fits(n+1, total+sizes[decl]) {
+			files = append(files, newFile(append([]ast.Decl(nil), header...), nil))
+			n, total = 0, headerSize
+		}
+		f := files[len(files)-1]
+		f.Decls = append(f.Decls, decl)
+		f.Comments = append(f.Comments, comments[decl]...)
+		n++
+		total += sizes[decl]
+	}
+	if files == nil {
+		return nil, nil
+	}
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		if !isInstantiatedDecl(decl) || keep[decl] {
+			decls = append(decls, decl)
+		}
+	}
+	file.Decls = decls
+	kept := file.Comments[:0]
+	for _, cg := range file.Comments {
+		if owner := owners[cg]; owner == nil || keep[owner] {
+			kept = append(kept, cg)
+		}
+	}
+	file.Comments = kept
+	return files, nil
+}
+
+// collectInstantiations moves the declarations created by
+// instantiation out of files, the translated files of a package,
+// into a new file that it returns. The new file has the package
+// clause of the first file, and the imports of all of the files
+// along with the references that keep them from being unused.
+// It is an error for two files to import different packages
+// under the same name.
+func collectInstantiations(importer *Importer, files []*ast.File) (*ast.File, error) {
+	var specs []ast.Spec
+	var refs, insts []ast.Decl
+	paths := make(map[string]string)
+	specSeen := make(map[[2]string]bool)
+	refSeen := make(map[[2]string]bool)
+	for _, file := range files {
+		pkgNames := importer.importNames[file]
+		imports := importNames(file, pkgNames)
+		keep := file.Decls[:0]
+		for _, decl := range file.Decls {
+			switch {
+			case isImportDecl(decl):
+				for _, spec := range decl.(*ast.GenDecl).Specs {
+					imp := spec.(*ast.ImportSpec)
+					name, path := importName(imp, pkgNames)
+					if p, ok := paths[name]; ok && p != path && name != "_" {
+						return nil, fmt.Errorf("cannot collect instantiations in one file: %s names both %q and %q", name, p, path)
+					}
+					paths[name] = path
+					if specSeen[[2]string{name, path}] {
+						continue
+					}
+					specSeen[[2]string{name, path}] = true
+					nspec := &ast.ImportSpec{
+						Path: &ast.BasicLit{Kind: token.STRING, Value: imp.Path.Value},
+					}
+					if imp.Name != nil {
+						nspec.Name = ast.NewIdent(imp.Name.Name)
+					}
+					specs = append(specs, nspec)
+				}
+			case isImportReference(decl, imports):
+				// Several files may refer to the same import.
+				sel := importReference(decl)
+				key := [2]string{sel.X.(*ast.Ident).Name, sel.Sel.Name}
+				if !refSeen[key] {
+					refSeen[key] = true
+					refs = append(refs, decl)
+				}
+			case isInstantiatedDecl(decl):
+				insts = append(insts, decl)
+				continue
+			}
+			keep = append(keep, decl)
+		}
+		file.Decls = keep
+	}
+
+	first := files[0]
+	shared := &ast.File{
+		Package: first.Package,
+		Name:    &ast.Ident{NamePos: first.Name.NamePos, Name: first.Name.Name},
+	}
+	if len(insts) > 0 {
+		if len(specs) > 0 {
+			shared.Decls = append(shared.Decls, &ast.GenDecl{Tok: token.IMPORT, Specs: specs})
+		}
+		shared.Decls = append(shared.Decls, refs...)
+		shared.Decls = append(shared.Decls, insts...)
+	}
+	return shared, nil
+}
+
+// importNames returns the names under which file refers to
+// the packages that it imports. The pkgNames parameter maps the
+// path of each package that file imports without naming it to
+// the name of the package, as recorded by rewriteAST.
+func importNames(file *ast.File, pkgNames map[string]string) map[string]bool {
+	names := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if !isImportDecl(decl) {
+			continue
+		}
+		for _, spec := range decl.(*ast.GenDecl).Specs {
+			name, _ := importName(spec.(*ast.ImportSpec), pkgNames)
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// importName returns the name under which imp refers to the
+// package that it imports, and the path of the package. The name
+// of a package imported without naming it is looked up in
+// pkgNames, as described for importNames.
+func importName(imp *ast.ImportSpec, pkgNames map[string]string) (name, path string) {
+	path = strings.TrimPrefix(strings.TrimSuffix(imp.Path.Value, `"`), `"`)
+	if imp.Name != nil {
+		return imp.Name.Name, path
+	}
+	return pkgNames[path], path
+}
+
+// isImportDecl reports whether decl is an import declaration.
+func isImportDecl(decl ast.Decl) bool {
+	gen, ok := decl.(*ast.GenDecl)
+	return ok && gen.Tok == token.IMPORT
+}
+
+// isImportReference reports whether decl is a reference to an
+// imported package, as added by rewriteAST, such as
+//
+//	type _ pkg.Name
+//
+// The imports parameter holds the names of the imported packages.
+func isImportReference(decl ast.Decl, imports map[string]bool) bool {
+	sel := importReference(decl)
+	if sel == nil {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && imports[x.Name]
+}
+
+// importReference returns the qualified name pkg.Name to which
+// decl refers if decl has the form of a reference to an imported
+// package, as described for isImportReference, and otherwise nil.
+func importReference(decl ast.Decl) *ast.SelectorExpr {
+	gen, ok := decl.(*ast.GenDecl)
+	if !ok || len(gen.Specs) != 1 {
+		return nil
+	}
+	var name *ast.Ident
+	var ref ast.Expr
+	switch spec := gen.Specs[0].(type) {
+	case *ast.TypeSpec:
+		name, ref = spec.Name, spec.Type
+	case *ast.ValueSpec:
+		if len(spec.Names) != 1 || len(spec.Values) != 1 {
+			return nil
+		}
+		name, ref = spec.Names[0], spec.Values[0]
+	default:
+		return nil
+	}
+	if name.Name != "_" {
+		return nil
+	}
+	sel, _ := ref.(*ast.SelectorExpr)
+	return sel
+}
+
+// isInstantiatedDecl reports whether decl was created by instantiating
+// a generic function or type, or is a method of an instantiated type.
+func isInstantiatedDecl(decl ast.Decl) bool {
+	isInst := func(name string) bool {
+		_, _, _, ok := ParseInstantiatedName(name)
+		return ok
+	}
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv != nil && len(decl.Recv.List) == 1 {
+			rtyp := decl.Recv.List[0].Type
+			if p, ok := rtyp.(*ast.StarExpr); ok {
+				rtyp = p.X
+			}
+			if id, ok := rtyp.(*ast.Ident); ok {
+				return isInst(id.Name)
+			}
+			return false
+		}
+		return isInst(decl.Name.Name)
+	case *ast.GenDecl:
+		if decl.Tok != token.TYPE || len(decl.Specs) == 0 {
+			return false
+		}
+		for _, spec := range decl.Specs {
+			if !isInst(spec.(*ast.TypeSpec).Name.Name) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -45,7 +45,7 @@ func (t *translator) setType(e ast.Expr, nt types.Type) {
 func (t *translator) instantiateType(ta *typeArgs, typ types.Type) types.Type {
 	if insts, ok := t.typeInstantiations[typ]; ok {
 		for _, inst := range insts {
-			if t.sameTypes(ta.types, inst.types) {
+			if !inst.external && t.sameTypes(ta.types, inst.types) {
 				return inst.typ
 			}
 		}
@@ -72,7 +72,7 @@ func (t *translator) doInstantiateType(ta *typeArgs, typ types.Type) types.Type
 		if elem == instElem {
 			return typ
 		}
-		return types.NewArray(elem, typ.Len())
+		return types.NewArray(instElem, typ.Len())
 	case *types.Slice:
 		elem := typ.Elem()
 		instElem := t.instantiateType(ta, elem)
@@ -92,7 +92,13 @@ func (t *translator) doInstantiateType(ta *typeArgs, typ types.Type) types.Type
 			if v.Type() != instType {
 				changed = true
 			}
-			fields[i] = types.NewVar(v.Pos(), v.Pkg(), v.Name(), instType)
+			// An embedded field takes the name of the type
+			// it embeds once instantiated.
+			name := v.Name()
+			if embName, ok := t.embeddedFieldName(v, instType); ok {
+				name = embName
+			}
+			fields[i] = types.NewField(v.Pos(), v.Pkg(), name, instType, v.Embedded())
 
 			tag := typ.Tag(i)
 			if tag != "" {
@@ -153,6 +159,8 @@ func (t *translator) doInstantiateType(ta *typeArgs, typ types.Type) types.Type
 		if !changed {
 			return typ
 		}
+		// The new interface has no type list, matching the
+		// method-only interface written by instantiateExpr.
 		return types.NewInterfaceType(methods, embeddeds)
 	case *types.Map:
 		key := t.instantiateType(ta, typ.Key())
@@ -225,6 +233,8 @@ func (t *translator) instantiateTypeTuple(ta *typeArgs, tuple *types.Tuple) *typ
 	}
 	vars := make([]*types.Var, l)
 	for i := 0; i < l; i++ {
+		// Keep the name even if it is empty or blank,
+		// so that unnamed results stay unnamed.
 		v := tuple.At(i)
 		vars[i] = types.NewVar(v.Pos(), v.Pkg(), v.Name(), instTypes[i])
 	}
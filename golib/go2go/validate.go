@@ -0,0 +1,132 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package go2go
+
+import (
+	"fmt"
+	"github.com/tdakkota/go2go/golib/ast"
+	"github.com/tdakkota/go2go/golib/parser"
+	"github.com/tdakkota/go2go/golib/token"
+	"github.com/tdakkota/go2go/golib/types"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// validate type checks the .go files generated in dir for pkgfiles,
+// to catch translation bugs before the output is compiled.
+// Because the generated files carry //line directives, errors are
+// reported against the .go2 files that they were translated from.
+// The files parameter is the number of files being translated.
+func validate(importer *Importer, dir string, pkgfiles []namedAST, files int) error {
+	fset := token.NewFileSet()
+	asts := make([]*ast.File, 0, len(pkgfiles))
+	var errs []*FileError
+	for _, pkgfile := range pkgfiles {
+		filename := filepath.Base(pkgfile.name)
+		gofiles := []string{importer.goFileName(filename)}
+		for n := 1; ; n++ {
+			name := splitFileName(filename, n)
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				break
+			}
+			gofiles = append(gofiles, name)
+		}
+		for _, gofile := range gofiles {
+			f, err := parser.ParseFile(fset, filepath.Join(dir, gofile), nil, 0)
+			if err != nil {
+				errs = append(errs, &FileError{Filename: filename, Err: fmt.Errorf("generated code does not parse:\n%v", err)})
+				continue
+			}
+			asts = append(asts, f)
+		}
+	}
+	// The instantiations made by the package itself are in the
+	// file named by the InstantiationsFile option.
+	if name := importer.opts.InstantiationsFile; name != "" && len(asts) > 0 && !strings.HasSuffix(asts[0].Name.Name, "_test") {
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			errs = append(errs, &FileError{Filename: name, Err: fmt.Errorf("generated code does not parse:\n%v", err)})
+		} else {
+			asts = append(asts, f)
+		}
+	}
+	if len(errs) > 0 {
+		return &MultiError{Files: files, Errs: errs}
+	}
+
+	var merr multiErr
+	conf := types.Config{
+		Importer: newGo1Importer(importer),
+		Error:    merr.add,
+	}
+	if _, err := conf.Check(asts[0].Name.Name, fset, asts, nil); err != nil {
+		r := merr.byFile(dir, asts[0].Name.Name, files)
+		for _, e := range r.Errs {
+			e.Err = fmt.Errorf("generated code does not type check:\n%v", e.Err)
+		}
+		return r
+	}
+	return nil
+}
+
+// A go1Importer imports the Go 1 code generated for the Go 2
+// packages that the Importer translated, so that generated code
+// is checked against the generated code of its dependencies.
+// Other imports are handled by the Importer.
+type go1Importer struct {
+	imp  *Importer
+	pkgs map[string]*types.Package
+}
+
+// newGo1Importer returns a go1Importer that uses imp.
+func newGo1Importer(imp *Importer) *go1Importer {
+	return &go1Importer{
+		imp:  imp,
+		pkgs: make(map[string]*types.Package),
+	}
+}
+
+// Import is part of the types.Importer interface.
+func (gi *go1Importer) Import(path string) (*types.Package, error) {
+	return gi.ImportFrom(path, "", 0)
+}
+
+// ImportFrom is part of the types.ImporterFrom interface.
+func (gi *go1Importer) ImportFrom(importPath, dir string, mode types.ImportMode) (*types.Package, error) {
+	tdir := gi.imp.translated[importPath]
+	if tdir == "" {
+		return gi.imp.ImportFrom(importPath, dir, mode)
+	}
+	if tpkg, ok := gi.pkgs[importPath]; ok {
+		return tpkg, nil
+	}
+
+	fset := token.NewFileSet()
+	filter := func(fi os.FileInfo) bool {
+		return filepath.Ext(fi.Name()) == ".go" && !strings.HasSuffix(fi.Name(), "_test.go")
+	}
+	pkgs, err := parser.ParseDir(fset, tdir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("importing %q: found %d packages in %s", importPath, len(pkgs), tdir)
+	}
+	var asts []*ast.File
+	for _, apkg := range pkgs {
+		for _, f := range apkg.Files {
+			asts = append(asts, f)
+		}
+	}
+
+	conf := types.Config{Importer: gi}
+	tpkg, err := conf.Check(importPath, fset, asts, nil)
+	if err != nil {
+		return nil, fmt.Errorf("importing %q: generated code does not type check: %v", importPath, err)
+	}
+	gi.pkgs[importPath] = tpkg
+	return tpkg, nil
+}
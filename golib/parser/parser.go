@@ -1370,16 +1370,35 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 	p.exprLev++
 	var index [N]ast.Expr
 	var colons [N - 1]token.Pos
+	var list []ast.Expr
 	if p.tok != token.COLON {
-		index[0] = p.parseRhs()
+		// The index may be a type argument, as in F[[]int].
+		index[0] = p.parseRhsOrType()
 	}
 	ncolons := 0
-	for p.tok == token.COLON && ncolons < len(colons) {
-		colons[ncolons] = p.pos
-		ncolons++
-		p.next()
-		if p.tok != token.COLON && p.tok != token.RBRACK && p.tok != token.EOF {
-			index[ncolons] = p.parseRhs()
+	switch p.tok {
+	case token.COLON:
+		// slice expression
+		if index[0] != nil {
+			index[0] = p.checkExpr(index[0])
+		}
+		for p.tok == token.COLON && ncolons < len(colons) {
+			colons[ncolons] = p.pos
+			ncolons++
+			p.next()
+			if p.tok != token.COLON && p.tok != token.RBRACK && p.tok != token.EOF {
+				index[ncolons] = p.parseRhs()
+			}
+		}
+	case token.COMMA:
+		// type argument list, as in F[int, string]
+		list = append(list, index[0])
+		for p.tok == token.COMMA {
+			p.next()
+			if p.tok == token.RBRACK || p.tok == token.EOF {
+				break
+			}
+			list = append(list, p.parseRhsOrType())
 		}
 	}
 	p.exprLev--
@@ -1404,6 +1423,9 @@ func (p *parser) parseIndexOrSlice(x ast.Expr) ast.Expr {
 		return &ast.SliceExpr{X: x, Lbrack: lbrack, Low: index[0], High: index[1], Max: index[2], Slice3: slice3, Rbrack: rbrack}
 	}
 
+	if len(list) > 1 {
+		return &ast.IndexListExpr{X: x, Lbrack: lbrack, Indices: list, Rbrack: rbrack}
+	}
 	return &ast.IndexExpr{X: x, Lbrack: lbrack, Index: index[0], Rbrack: rbrack}
 }
 
@@ -1534,6 +1556,7 @@ func (p *parser) checkExpr(x ast.Expr) ast.Expr {
 		panic("unreachable")
 	case *ast.SelectorExpr:
 	case *ast.IndexExpr:
+	case *ast.IndexListExpr:
 	case *ast.SliceExpr:
 	case *ast.TypeAssertExpr:
 		// If t.Type == nil we have a type assertion of the form
@@ -1643,7 +1666,7 @@ func (p *parser) parsePrimaryExpr(lhs bool) (x ast.Expr) {
 			t := unparen(x)
 			// determine if '{' belongs to a composite literal or a block statement
 			switch t.(type) {
-			case *ast.BadExpr, *ast.Ident, *ast.SelectorExpr, *ast.CallExpr: // *ast.CallExpr for instantiated types
+			case *ast.BadExpr, *ast.Ident, *ast.SelectorExpr, *ast.CallExpr, *ast.IndexExpr, *ast.IndexListExpr: // *ast.CallExpr, *ast.IndexExpr and *ast.IndexListExpr for instantiated types
 				if p.exprLev < 0 {
 					return
 				}
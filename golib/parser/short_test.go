@@ -110,6 +110,13 @@ var valids = []string{
 	`package p; type I1 interface{}; type I2 interface{ (I1) }`,
 	`package p; type I1(type T) interface{}; type I2 interface{ (I1(int)) }`,
 	`package p; type I1(type T) interface{}; type I2(type T) interface{ (I1(T)) }`,
+
+	// instantiations written with index syntax
+	`package p; var _ = F[int]`,
+	`package p; var _ = F[int, string](1)`,
+	`package p; var _ = F[[]int, map[string]int, List(int),]`,
+	`package p; var _ = pkg.L[int]{}`,
+	`package p; func f() { x := L[int]{}; if x == (L[int]{}) {} }`,
 }
 
 func TestValid(t *testing.T) {
@@ -207,6 +214,9 @@ var invalids = []string{
 	// issue 13475
 	`package p; func f() { if true {} else ; /* ERROR "expected if statement or block" */ }`,
 	`package p; func f() { if true {} else defer /* ERROR "expected if statement or block" */ f() }`,
+
+	// instantiations written with index syntax
+	`package p; var _ = F[int, string: /* ERROR "expected ']', found ':'" */ ]`,
 }
 
 func TestInvalid(t *testing.T) {
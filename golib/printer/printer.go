@@ -89,6 +89,9 @@ type printer struct {
 	// Cache of most recently computed line position.
 	cachedPos  token.Pos
 	cachedLine int // line corresponding to cachedPos
+
+	// Cache of the file most recently used by adjustedPos.
+	cachedFile *token.File
 }
 
 func (p *printer) init(cfg *Config, fset *token.FileSet, nodeSizes map[ast.Node]int) {
@@ -205,6 +208,9 @@ func (p *printer) lineFor(pos token.Pos) int {
 
 // writeLineDirective writes a //line directive if necessary.
 func (p *printer) writeLineDirective(pos token.Position) {
+	if p.Config.Mode&AdjustedPos != 0 {
+		pos = p.adjustedPos(pos)
+	}
 	if pos.IsValid() && (p.out.Line != pos.Line || p.out.Filename != pos.Filename) {
 		p.output = append(p.output, tabwriter.Escape) // protect '\n' in //line from tabwriter interpretation
 		p.output = append(p.output, fmt.Sprintf("//line %s:%d\n", pos.Filename, pos.Line)...)
@@ -215,6 +221,33 @@ func (p *printer) writeLineDirective(pos token.Position) {
 	}
 }
 
+// adjustedPos returns the position pos as modified by any //line
+// directives in the source. pos must be an absolute position.
+func (p *printer) adjustedPos(pos token.Position) token.Position {
+	if !pos.IsValid() {
+		return pos
+	}
+	f := p.cachedFile
+	if f == nil || f.Name() != pos.Filename {
+		f = nil
+		p.fset.Iterate(func(tf *token.File) bool {
+			if tf.Name() == pos.Filename {
+				f = tf
+				return false
+			}
+			return true
+		})
+		if f == nil {
+			return pos
+		}
+		p.cachedFile = f
+	}
+	if pos.Offset > f.Size() {
+		return pos
+	}
+	return p.fset.PositionFor(f.Pos(pos.Offset), true)
+}
+
 // writeIndent writes indentation.
 func (p *printer) writeIndent() {
 	// use "hard" htabs - indentation columns
@@ -1280,6 +1313,7 @@ const (
 	TabIndent                  // use tabs for indentation independent of UseSpaces
 	UseSpaces                  // use spaces instead of tabs for alignment
 	SourcePos                  // emit //line directives to preserve original source positions
+	AdjustedPos                // with SourcePos, emit positions as adjusted by //line directives in the source
 )
 
 // A Config node controls the output of Fprint.
@@ -44,8 +44,35 @@ type Error struct {
 	Msg  string         // default error message, user-friendly
 	Full string         // full error message, for debugging (may contain internal details)
 	Soft bool           // if set, error is "soft"
+	Code ErrorCode      // error classification; UnknownError if not classified
+	Warn bool           // if set, error was downgraded to a warning (see Config.Severity)
 }
 
+// An ErrorCode classifies an Error, so that tools can recognize
+// particular kinds of errors without matching on the message text.
+// Most errors are not classified and have code UnknownError.
+type ErrorCode int
+
+const (
+	UnknownError               ErrorCode = iota
+	DuplicateDecl                        // an identifier is declared twice in the same block
+	UndeclaredName                       // an identifier is not declared
+	UnusedVar                            // a local variable is declared but not used
+	UnusedImport                         // an imported package is not used
+	UnusedLabel                          // a label is declared but not used
+	UnsatisfiedConstraint                // a type argument does not satisfy its constraint
+	IntToStringConversion                // a non-constant integer is converted to a string
+	UnconstrainedContractParam           // a contract places no constraint on a type parameter
+)
+
+// A Severity determines how an error with a given ErrorCode is reported.
+type Severity int
+
+const (
+	SeverityError   Severity = iota // reported as an error (the default)
+	SeverityWarning                 // reported as a warning; checking continues
+)
+
 // Error returns an error string formatted as follows:
 // filename:line:column: message
 func (err Error) Error() string {
@@ -101,7 +128,9 @@ type ImporterFrom interface {
 // The zero value for Config is a ready-to-use default configuration.
 type Config struct {
 	// If IgnoreFuncBodies is set, function bodies are not
-	// type-checked.
+	// type-checked. This includes the bodies of generic functions,
+	// of the methods of generic types, and of function literals.
+	// Signatures, and the instantiations in them, are still checked.
 	IgnoreFuncBodies bool
 
 	// If FakeImportC is set, `import "C"` (for packages requiring Cgo)
@@ -125,6 +154,13 @@ type Config struct {
 	// error found.
 	Error func(err error)
 
+	// Severity reclassifies errors by their ErrorCode. Errors whose
+	// code maps to SeverityWarning are reported to Error with the
+	// Warn field set; they do not stop type-checking and are not
+	// returned by Check. If Error == nil, warnings are dropped.
+	// Errors with code UnknownError are never reclassified.
+	Severity map[ErrorCode]Severity
+
 	// An importer is used to import packages referred to from
 	// import declarations.
 	// If the installed importer implements ImporterFrom, the type
@@ -137,9 +173,31 @@ type Config struct {
 	// Otherwise SizesFor("gc", "amd64") is used instead.
 	Sizes Sizes
 
+	// If ContractResolver != nil, it is called to resolve a qualified
+	// contract name pkg.name in a type parameter bound, before pkg's
+	// scope is consulted. The result must be a fully set up contract,
+	// such as one declared by a type-checked package. If the result
+	// is nil, the contract is looked up in the scope of pkg.
+	ContractResolver func(pkg *Package, name string) *Contract
+
 	// If DisableUnusedImportCheck is set, packages are not checked
 	// for unused imports.
 	DisableUnusedImportCheck bool
+
+	// If ReportIntToString is set, conversions string(x) of a
+	// non-constant integer x whose type is not byte or rune are
+	// reported with code IntToStringConversion: they yield a string
+	// of one rune, not a string of digits, which is rarely what was
+	// meant. Use Severity to report them as warnings.
+	ReportIntToString bool
+
+	// If ReportUnconstrainedContractParams is set, the type
+	// parameters of a contract that the contract does not constrain,
+	// directly or through an embedded contract, are reported with
+	// code UnconstrainedContractParam: their bound is the empty
+	// interface, so any type argument satisfies it, which may not
+	// be what was meant. Use Severity to report them as warnings.
+	ReportUnconstrainedContractParams bool
 }
 
 // Info holds result type information for a type-checked package.
@@ -334,6 +392,14 @@ type Inferred struct {
 	Sig   *Signature
 }
 
+// An Instance describes an instantiation of a generic function
+// or type: Obj is the generic *Func or *TypeName, and Targs are
+// the type arguments it was instantiated with.
+type Instance struct {
+	Obj   Object
+	Targs []Type
+}
+
 // An Initializer describes a package-level variable, or a list of variables in case
 // of a multi-valued initialization expression, and the corresponding initialization
 // expression.
@@ -395,6 +461,48 @@ func Implements(V Type, T *Interface) bool {
 	return f == nil
 }
 
+// An UnsatisfiedError describes a type argument that does not
+// satisfy the bound that a contract places on the corresponding
+// type parameter, as reported by SatisfiesContract.
+type UnsatisfiedError struct {
+	TParam *TypeName // type parameter of the contract
+	TArg   Type      // type argument
+	Msg    string    // why TArg does not satisfy the bound of TParam
+}
+
+// Error returns the message of err, preceded by the name of the
+// type parameter.
+func (err *UnsatisfiedError) Error() string {
+	return fmt.Sprintf("%s: %s", err.TParam.name, err.Msg)
+}
+
+// SatisfiesContract reports whether the type arguments targs satisfy
+// the bounds that contract c places on its type parameters, the way
+// they are checked when a generic function or type constrained by c
+// is instantiated. If not, it also returns an error for each type
+// argument, in order, that does not satisfy its bound.
+// SatisfiesContract panics if the number of type arguments is not
+// the number of type parameters of c.
+func SatisfiesContract(c *Contract, targs []Type) (bool, []*UnsatisfiedError) {
+	if len(targs) != len(c.TParams) {
+		panic(fmt.Sprintf("got %d type arguments but contract %s expects %d", len(targs), c.name, len(c.TParams)))
+	}
+	check := NewChecker(nil, token.NewFileSet(), c.pkg, nil)
+	smap := makeSubstMap(c.TParams, targs)
+	var errs []*UnsatisfiedError
+	for i, tname := range c.TParams {
+		iface := c.Bounds[i].underlying.(*Interface)
+		// The positions of embedded contracts are only needed
+		// for error reporting by completeInterface.
+		check.posMap[iface] = make([]token.Pos, len(iface.embeddeds))
+		iface = check.subst(token.NoPos, iface, smap).(*Interface)
+		if msg := check.unsatisfied(targs[i], "contract "+c.name, iface); msg != "" {
+			errs = append(errs, &UnsatisfiedError{TParam: tname, TArg: targs[i], Msg: msg})
+		}
+	}
+	return len(errs) == 0, errs
+}
+
 // Identical reports whether x and y are identical types.
 // Receivers of Signature types are ignored.
 func Identical(x, y Type) bool {
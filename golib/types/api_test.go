@@ -14,6 +14,7 @@ import (
 	"github.com/tdakkota/go2go/testutil/testenv"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"testing"
 
@@ -56,6 +57,79 @@ func mayTypecheck(t *testing.T, path, source string, info *Info) (string, error)
 	return pkg.Name(), err
 }
 
+func TestAssignableTo(t *testing.T) {
+	const src = `
+package p
+
+type MyInt int
+type Ints []int
+type C chan int
+
+func F(type P interface{ type int, int64 }, S interface{ type []int }, B interface{ type []int, []byte }, A interface{})() {
+	_ = 0
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// evaluate types inside F, where its type parameters are in scope
+	pos := f.Decls[len(f.Decls)-1].(*ast.FuncDecl).Body.Lbrace + 1
+
+	for _, test := range []struct {
+		v, t string
+		want bool
+	}{
+		// the same results as for Go 1
+		{"int", "int", true},
+		{"MyInt", "int", false},
+		{"int", "MyInt", false},
+		{"[]int", "Ints", true},
+		{"Ints", "[]int", true},
+		{"chan int", "C", true},
+		{"C", "<-chan int", true},
+		{"<-chan int", "C", false},
+		{"nil", "Ints", true},
+		{"nil", "int", false},
+		{"int", "interface{}", true},
+
+		// type parameters
+		{"P", "P", true},
+		{"P", "interface{}", true},
+		{"P", "int", false},
+		{"int", "P", false},
+		{"[]int", "S", true},
+		{"Ints", "S", false},
+		{"nil", "S", true},
+		{"nil", "P", false},
+		{"[]int", "B", false},
+		{"[]int", "A", false},
+		{"S", "[]int", true},
+		{"S", "Ints", false},
+		{"B", "[]int", false},
+		{"A", "[]int", false},
+		{"S", "B", false},
+	} {
+		V, err := Eval(fset, pkg, pos, test.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		T, err := Eval(fset, pkg, pos, test.t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := AssignableTo(V.Type, T.Type); got != test.want {
+			t.Errorf("AssignableTo(%s, %s) = %v, want %v", test.v, test.t, got, test.want)
+		}
+	}
+}
+
 func TestValuesInfo(t *testing.T) {
 	var tests = []struct {
 		src  string
@@ -290,6 +364,18 @@ func TestTypesInfo(t *testing.T) {
 
 		// instantiated types must be sanitized
 		{`package g0; type t(type P) int; var x struct{ f t(int) }; var _ = x.f`, `x.f`, `g0.t(int)`},
+
+		// nil converted to instantiated types
+		{`package n0; type t(type P) struct{ f P }; var _ = (*t(int))(nil)`, `(*t(int))(nil)`, `*n0.t(int)`},
+		{`package n1; type t(type P) struct{ f P }; var _ = (*t(int))(nil)`, `nil`, `*n1.t(int)`},
+		{`package n2; type t(type P) []P; var _ = t(int)(nil)`, `nil`, `n2.t(int)`},
+		{`package n3; type t(type K comparable) map[K]K; var _ = t(string)(nil)`, `nil`, `n3.t(string)`},
+		{`package n4; type t(type P) chan P; var _ = t(int)(nil)`, `nil`, `n4.t(int)`},
+		{`package n5; type t(type P) func(P); var _ = t(int)(nil)`, `nil`, `n5.t(int)`},
+		{`package n6; type t(type P) interface{ m() P }; var _ = t(int)(nil)`, `t(int)(nil)`, `n6.t(int)`},
+		{`package n7; type t(type P) interface{ m() P }; var _ = t(int)(nil)`, `nil`, `untyped nil`},
+		{`package n8; type t(type P) struct{ f P }; var _ *t(int) = nil`, `nil`, `untyped nil`},
+		{`package n9; type t(type P) struct{ f P }; func _(type Q)() { _ = (*t(Q))(nil) }`, `nil`, `*n9.t(Q₂)`},
 	}
 
 	for _, test := range tests {
@@ -430,6 +516,47 @@ func TestInferredInfo(t *testing.T) {
 	}
 }
 
+func TestInstances(t *testing.T) {
+	const src = `
+package p
+
+type List(type T) []T
+
+func Map(type T, U)(s []T, f func(T) U) []U { return nil }
+
+func _() {
+	var _ List(int)
+	var _ List(int)
+	_ = Map([]int{}, func(int) string { return "" })
+	_ = Map(string, bool)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	check := NewChecker(&conf, fset, NewPackage("p", "p"), nil)
+	if err := check.Files([]*ast.File{f}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, inst := range check.Instances() {
+		var targs []string
+		for _, targ := range inst.Targs {
+			targs = append(targs, targ.String())
+		}
+		got = append(got, fmt.Sprintf("%s(%s)", inst.Obj.Name(), strings.Join(targs, ", ")))
+	}
+	sort.Strings(got)
+	want := []string{"List(int)", "Map(int, string)", "Map(string, bool)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got instances %v; want %v", got, want)
+	}
+}
+
 func TestDefsInfo(t *testing.T) {
 	var tests = []struct {
 		src  string
@@ -1043,6 +1170,399 @@ func TestFiles(t *testing.T) {
 	}
 }
 
+func TestDependencies(t *testing.T) {
+	const src = `
+package p
+
+type T int
+
+const c = 1
+
+var (
+	a = b + f()
+	b = c
+	x T
+)
+
+func f() int { return g(x) }
+func g(T) int { return 0 }
+func (T) m() int { return a }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	check := NewChecker(&conf, fset, NewPackage("p", "p"), nil)
+	if err := check.Files([]*ast.File{f}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"T": "[]",
+		"c": "[]",
+		"a": "[b f]",
+		"b": "[c]",
+		"x": "[]",
+		"f": "[x g]",
+		"g": "[]",
+	}
+	got := make(map[string]string)
+	for obj, deps := range check.Dependencies() {
+		var names []string
+		for _, dep := range deps {
+			names = append(names, dep.Name())
+		}
+		got[obj.Name()] = fmt.Sprint(names)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got dependencies %v, want %v", got, want)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	const src = `package p
+
+func f() {
+	var x int
+	var x string
+	_ = y
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []Error
+	conf := Config{Error: func(err error) { errs = append(errs, err.(Error)) }}
+	conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+
+	want := []struct {
+		line, col int
+		code      ErrorCode
+		msg       string
+	}{
+		{5, 6, DuplicateDecl, "x redeclared in this block"},
+		{4, 6, UnknownError, "\tother declaration of x"},
+		{6, 6, UndeclaredName, "undeclared name: y"},
+		{4, 6, UnusedVar, "x declared but not used"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, err := range errs {
+		w := want[i]
+		if !err.Pos.IsValid() {
+			t.Errorf("%s: invalid position", err.Msg)
+			continue
+		}
+		pos := fset.Position(err.Pos)
+		if pos.Line != w.line || pos.Column != w.col || err.Code != w.code || err.Msg != w.msg {
+			t.Errorf("got %d:%d: %q (code %d), want %d:%d: %q (code %d)",
+				pos.Line, pos.Column, err.Msg, err.Code, w.line, w.col, w.msg, w.code)
+		}
+	}
+}
+
+func TestIgnoreFuncBodies(t *testing.T) {
+	const src = `package p
+
+type List(type T) []T
+
+func (l List(T)) Len() int { return undeclared1 }
+
+func Map(type T, U)(l List(T), f func(T) U) List(U) {
+	var unused int
+	return l
+}
+
+func Sum(type T interface{ type int, float64 })(l List(T)) T {
+	var s T
+	for _, v := range l {
+		s += v.x
+	}
+	return s
+}
+
+var F = func() int { return undeclared2 }
+
+var G = Map(List(int){1}, func(x int) string { return x })
+
+func Bad(l List(undeclared3)) {}
+
+type Set(type K comparable) map[K]bool
+
+func Bad2(s Set(func())) { return 1 }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go2", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	conf := Config{
+		IgnoreFuncBodies: true,
+		Error:            func(err error) { got = append(got, err.Error()) },
+	}
+	conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	want := []string{
+		"p.go2:24:17: undeclared name: undeclared3",
+		"p.go2:28:17: func() does not satisfy comparable",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// The bodies do have errors.
+	got = nil
+	conf.IgnoreFuncBodies = false
+	conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	if len(got) <= len(want) {
+		t.Errorf("checking bodies: got %q, want more errors", got)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	const src = `package p
+
+type Stringer interface{ String() string }
+
+func Print(type T Stringer)(x T) {}
+
+func f() {
+	Print(0)
+	var s string = 1
+	_ = s
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a Severity map, the constraint violation is an error.
+	var conf Config
+	_, err = conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	if err, ok := err.(Error); !ok || err.Code != UnsatisfiedConstraint || err.Warn {
+		t.Fatalf("got %v, want unsatisfied constraint error", err)
+	}
+
+	// With the constraint violation downgraded to a warning,
+	// checking continues and reports the following error.
+	var errs []Error
+	conf = Config{
+		Error:    func(err error) { errs = append(errs, err.(Error)) },
+		Severity: map[ErrorCode]Severity{UnsatisfiedConstraint: SeverityWarning},
+	}
+	_, err = conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(errs), errs)
+	}
+	if w := errs[0]; w.Code != UnsatisfiedConstraint || !w.Warn || !w.Soft {
+		t.Errorf("got %v (code %d, warn %t), want unsatisfied constraint warning", w, w.Code, w.Warn)
+	}
+	if e := errs[1]; e.Warn || !strings.Contains(e.Msg, "cannot convert 1") {
+		t.Errorf("got %v, want assignment error", e)
+	}
+	if err != errs[1] {
+		t.Errorf("Check returned %v, want %v", err, errs[1])
+	}
+
+	// Warnings alone do not make checking fail, even without an Error handler.
+	f, err = parser.ParseFile(fset, "q.go", "package q; func F(type T interface{ m() })(T) {}; var _ = F(int)", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf = Config{Severity: map[ErrorCode]Severity{UnsatisfiedConstraint: SeverityWarning}}
+	if _, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil); err != nil {
+		t.Errorf("got %v, want no error", err)
+	}
+}
+
+func TestReportUnconstrainedContractParams(t *testing.T) {
+	const src = `package p
+
+contract Stringer(T) {
+	T String() string
+}
+
+contract Pair(K, V) {
+	K String() string
+}
+
+contract Embed(A, B) {
+	Stringer(A)
+	Stringer(B)
+}
+
+contract Numeric(T) {
+	T int, float64
+}
+
+contract None(X) {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go2", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// By default, the type parameters are not reported.
+	var conf Config
+	if _, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []Error
+	conf = Config{
+		Error:                             func(err error) { errs = append(errs, err.(Error)) },
+		Severity:                          map[ErrorCode]Severity{UnconstrainedContractParam: SeverityWarning},
+		ReportUnconstrainedContractParams: true,
+	}
+	if _, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, err := range errs {
+		if err.Code != UnconstrainedContractParam || !err.Warn {
+			t.Errorf("got %v (code %d, warn %t), want unconstrained type parameter warning", err, err.Code, err.Warn)
+		}
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"p.go2:7:18: type parameter V of contract Pair is unconstrained (equivalent to interface{})",
+		"p.go2:20:15: type parameter X of contract None is unconstrained (equivalent to interface{})",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSatisfiesContract(t *testing.T) {
+	const src = `package p
+
+contract Integer(T) {
+	T int, int8, int16, int32, int64
+}
+
+contract Stringer(T) {
+	T String() string
+}
+
+contract Both(K, V) {
+	Integer(K)
+	Stringer(V)
+}
+
+type MyInt int
+
+func (MyInt) String() string { return "" }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go2", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := func(name string) *Contract {
+		return pkg.Scope().Lookup(name).(*Contract)
+	}
+	myInt := pkg.Scope().Lookup("MyInt").Type()
+
+	for _, test := range []struct {
+		contract string
+		targs    []Type
+		want     []string
+	}{
+		{"Integer", []Type{Typ[Int]}, nil},
+		{"Integer", []Type{myInt}, nil},
+		{"Integer", []Type{Typ[String]}, []string{
+			"T: string does not satisfy contract Integer (string not found in [int int8 int16 int32 int64])",
+		}},
+		{"Stringer", []Type{myInt}, nil},
+		{"Stringer", []Type{Typ[Int]}, []string{
+			"T: int does not satisfy contract Stringer (missing method String)",
+		}},
+		{"Both", []Type{Typ[Int64], myInt}, nil},
+		{"Both", []Type{Typ[String], Typ[Int]}, []string{
+			"K: string does not satisfy contract Both (string not found in [int int8 int16 int32 int64])",
+			"V: int does not satisfy contract Both (missing method String)",
+		}},
+	} {
+		ok, errs := SatisfiesContract(contract(test.contract), test.targs)
+		var got []string
+		for _, err := range errs {
+			got = append(got, err.Error())
+		}
+		if ok != (len(test.want) == 0) || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SatisfiesContract(%s, %v) = %t, %q; want %q", test.contract, test.targs, ok, got, test.want)
+		}
+	}
+}
+
+func TestReportIntToString(t *testing.T) {
+	const src = `package p
+
+type MyString string
+
+func itoa(i int) string { return "" }
+
+func f(i int, u uint8, r rune, b byte, s string) {
+	_ = string(i)
+	_ = MyString(u)
+	_ = string(r)
+	_ = string(b)
+	_ = string(65)
+	_ = []byte(s)
+	_ = string([]byte(s))
+	_ = itoa(i)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// By default, the conversions are not reported.
+	var conf Config
+	if _, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []Error
+	conf = Config{
+		Error:             func(err error) { errs = append(errs, err.(Error)) },
+		Severity:          map[ErrorCode]Severity{IntToStringConversion: SeverityWarning},
+		ReportIntToString: true,
+	}
+	if _, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, err := range errs {
+		if err.Code != IntToStringConversion || !err.Warn {
+			t.Errorf("got %v (code %d, warn %t), want int to string warning", err, err.Code, err.Warn)
+		}
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"p.go:8:13: conversion from int to string yields a string of one rune, not a string of digits",
+		"p.go:9:15: conversion from uint8 to MyString yields a string of one rune, not a string of digits",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
 type testImporter map[string]*Package
 
 func (m testImporter) Import(path string) (*Package, error) {
@@ -1052,6 +1572,111 @@ func (m testImporter) Import(path string) (*Package, error) {
 	return nil, fmt.Errorf("package %q not found", path)
 }
 
+func TestTypeParamBound(t *testing.T) {
+	const src = `package p
+
+contract stringer(T) {
+	T String() string
+}
+
+contract pair(K, V) {
+	K Key() int
+	V Value() V
+}
+
+func Iface(type T interface{ String() string })(T)
+func Contract(type T stringer)(T)
+func Call(type K, V pair(K, V))(K, V)
+func Free(type T)(T)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go2", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	pkg, err := conf.Check(f.Name.Name, fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		fun     string
+		methods []string // method names of each type parameter's bound
+	}{
+		{"Iface", []string{"String"}},
+		{"Contract", []string{"String"}},
+		{"Call", []string{"Key", "Value"}},
+		{"Free", []string{""}},
+	} {
+		sig := pkg.Scope().Lookup(test.fun).Type().(*Signature)
+		tparams := sig.TParams()
+		if len(tparams) != len(test.methods) {
+			t.Errorf("%s: got %d type parameters, want %d", test.fun, len(tparams), len(test.methods))
+			continue
+		}
+		for i, tpar := range tparams {
+			bound := tpar.Type().(*TypeParam).Bound()
+			var names []string
+			for j := 0; j < bound.NumMethods(); j++ {
+				names = append(names, bound.Method(j).Name())
+			}
+			if got := strings.Join(names, ","); got != test.methods[i] {
+				t.Errorf("%s: bound of %s has methods %q, want %q", test.fun, tpar.Name(), got, test.methods[i])
+			}
+		}
+	}
+}
+
+func TestUnsafeBuiltinTypeParams(t *testing.T) {
+	const src = `package p
+
+import "unsafe"
+
+type Pair(type T) struct {
+	a byte
+	b T
+}
+
+func Size(type T)(x T) uintptr { return unsafe.Sizeof(x) }
+func Align(type T)(x T) uintptr { return (unsafe.Alignof)(x) }
+func Offset(type T)(p Pair(T)) uintptr { return unsafe.Offsetof(p.b) }
+`
+	info := Info{Types: make(map[ast.Expr]TypeAndValue)}
+	mustTypecheck(t, "p.go2", src, &info)
+
+	want := map[string]bool{
+		"unsafe.Sizeof":    true,
+		"unsafe.Alignof":   true,
+		"(unsafe.Alignof)": true,
+		"unsafe.Offsetof":  true,
+	}
+	for e, tv := range info.Types {
+		s := ExprString(e)
+		if !want[s] {
+			continue
+		}
+		delete(want, s)
+		if !tv.IsBuiltin() {
+			t.Errorf("%s: not recorded as a built-in", s)
+		}
+		sig, _ := tv.Type.(*Signature)
+		if sig == nil || sig.Params().Len() != 1 || sig.Results().Len() != 1 {
+			t.Errorf("%s: got type %s, want func(T) uintptr", s, tv.Type)
+			continue
+		}
+		if _, ok := sig.Params().At(0).Type().(*TypeParam); !ok {
+			t.Errorf("%s: got parameter type %s, want a type parameter", s, sig.Params().At(0).Type())
+		}
+		if res := sig.Results().At(0).Type(); res != Typ[Uintptr] {
+			t.Errorf("%s: got result type %s, want uintptr", s, res)
+		}
+	}
+	for s := range want {
+		t.Errorf("no type recorded for %s", s)
+	}
+}
+
 func TestSelection(t *testing.T) {
 	selections := make(map[*ast.SelectorExpr]*Selection)
 
@@ -1688,3 +2313,314 @@ func f(x T) T { return foo.F(x) }
 		}
 	}
 }
+
+func TestContractResolver(t *testing.T) {
+	// The contract Stringer is declared by package c, but used
+	// as if it were declared by package q, which is empty.
+	const csrc = `
+package c
+
+contract Stringer(T) {
+	T String() string
+}
+`
+	const src = `
+package p
+
+import "q"
+
+func F(type T q.Stringer)(x T) string {
+	return x.String()
+}
+`
+	fset := token.NewFileSet()
+	cf, err := parser.ParseFile(fset, "c.go", csrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cconf Config
+	cpkg, err := cconf.Check("c", fset, []*ast.File{cf}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stringer := cpkg.Scope().Lookup("Stringer").(*Contract)
+
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewPackage("q", "q")
+	q.MarkComplete()
+	var resolved []string
+	conf := Config{
+		Importer: importHelper{q},
+		ContractResolver: func(pkg *Package, name string) *Contract {
+			resolved = append(resolved, pkg.Path()+"."+name)
+			if pkg == q && name == "Stringer" {
+				return stringer
+			}
+			return nil
+		},
+	}
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"q.Stringer"}; !reflect.DeepEqual(resolved, want) {
+		t.Errorf("resolved %v, want %v", resolved, want)
+	}
+
+	sig := pkg.Scope().Lookup("F").Type().(*Signature)
+	bound := sig.TParams()[0].Type().(*TypeParam).Bound()
+	if bound.NumMethods() != 1 || bound.Method(0).Name() != "String" {
+		t.Errorf("bound of T is %s, want the bound of contract c.Stringer", bound)
+	}
+}
+
+func TestIsInstance(t *testing.T) {
+	const src = `
+package p
+
+type List(type T) []T
+
+type Ints List(int)
+
+var L List(string)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := pkg.Scope().Lookup("List").Type().(*Named)
+	if list.IsInstance() {
+		t.Errorf("%s.IsInstance() = true, want false", list)
+	}
+	ints := pkg.Scope().Lookup("Ints").Type().(*Named)
+	if ints.IsInstance() {
+		t.Errorf("%s.IsInstance() = true, want false", ints)
+	}
+	l := pkg.Scope().Lookup("L").Type().Named()
+	if !l.IsInstance() {
+		t.Errorf("%s.IsInstance() = false, want true", l)
+	}
+
+	// A named type created for an instantiation, as the go2go
+	// translator does, is an instance once its type arguments
+	// are set.
+	obj := NewTypeName(token.NoPos, pkg, "instantiate୦୦List୦string", nil)
+	inst := NewNamed(obj, l.Underlying(), nil)
+	if inst.IsInstance() {
+		t.Errorf("%s.IsInstance() = true before SetTArgs, want false", inst)
+	}
+	inst.SetTArgs(l.TArgs())
+	if !inst.IsInstance() {
+		t.Errorf("%s.IsInstance() = false after SetTArgs, want true", inst)
+	}
+}
+
+func TestTParamsWithBounds(t *testing.T) {
+	const src = `
+package p
+
+type Stringer interface{ String() string }
+
+contract Comparer(T) {
+	T Less(T) bool
+}
+
+contract Lists(E, L) {
+	E Len() int
+	L Len() int
+}
+
+func F(type T Comparer, S Stringer, A interface{})() {}
+
+func G(type E, L Lists)() {}
+
+func H() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		fun  string
+		want []string // name and methods of the bound of each type parameter
+	}{
+		{"F", []string{"T: Less", "S: String", "A: "}},
+		{"G", []string{"E: Len", "L: Len"}},
+		{"H", nil},
+	} {
+		sig := pkg.Scope().Lookup(test.fun).Type().(*Signature)
+		var got []string
+		for _, b := range sig.TParamsWithBounds() {
+			iface := b.Bound.Underlying().(*Interface).Complete()
+			var methods []string
+			for i := 0; i < iface.NumMethods(); i++ {
+				methods = append(methods, iface.Method(i).Name())
+			}
+			got = append(got, b.Name+": "+strings.Join(methods, ", "))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.fun, got, test.want)
+		}
+	}
+}
+
+// TestTypeParamOutOfScope checks that an identifier denoting a type
+// parameter is resolved by name in the scope in which it appears,
+// even when the very same AST node also appears in the declaration
+// of the type parameter.
+func TestTypeParamOutOfScope(t *testing.T) {
+	const src = `
+package p
+
+func f(type T)(x T) {}
+
+func g() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reuse the identifier T of the parameter list of f
+	// in a declaration in the body of g.
+	fdecl := f.Decls[0].(*ast.FuncDecl)
+	tident := fdecl.Type.Params.List[0].Type.(*ast.Ident)
+	gdecl := f.Decls[1].(*ast.FuncDecl)
+	gdecl.Body.List = append(gdecl.Body.List, &ast.DeclStmt{
+		Decl: &ast.GenDecl{
+			Tok: token.VAR,
+			Specs: []ast.Spec{
+				&ast.ValueSpec{
+					Names: []*ast.Ident{ast.NewIdent("_")},
+					Type:  tident,
+				},
+			},
+		},
+	})
+
+	var conf Config
+	_, err = conf.Check("p", fset, []*ast.File{f}, nil)
+	const want = "undeclared name: T"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v; want %q", err, want)
+	}
+}
+
+func TestTypeParamMethodSet(t *testing.T) {
+	const src = `
+package p
+
+type Sizer interface {
+	Size() int
+}
+
+func F(type T interface{ String() string; Sizer })(x T) {}
+
+contract Named(T) {
+	T Name() string
+}
+
+func G(type T Named)(x T) {}
+
+func H(type T)(x T) {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		fun   string
+		names []string
+	}{
+		{"F", []string{"Size", "String"}},
+		{"G", []string{"Name"}},
+		{"H", nil},
+	} {
+		tpar := pkg.Scope().Lookup(test.fun).Type().(*Signature).TParams()[0].Type()
+		for _, typ := range []Type{tpar, NewPointer(tpar)} {
+			mset := NewMethodSet(typ)
+			var names []string
+			for i := 0; i < mset.Len(); i++ {
+				sel := mset.At(i)
+				if sel.Recv() != typ {
+					t.Errorf("%s: receiver of %s is %s, want %s", test.fun, sel.Obj().Name(), sel.Recv(), typ)
+				}
+				names = append(names, sel.Obj().Name())
+			}
+			if !reflect.DeepEqual(names, test.names) {
+				t.Errorf("%s: method set of %s has %v, want %v", test.fun, typ, names, test.names)
+			}
+		}
+	}
+}
+
+func TestInstanceUnderlying(t *testing.T) {
+	const src = `
+package p
+
+type List(type T) struct {
+	next *List(T)
+	val  T
+}
+
+var L List(int)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	pkg, err := conf.Check("p", fset, []*ast.File{f}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The underlying type of the parameterized type refers to T.
+	list := pkg.Scope().Lookup("List").Type().(*Named)
+	if _, ok := list.Underlying().(*Struct).Field(1).Type().(*TypeParam); !ok {
+		t.Errorf("field val of %s has type %s, want a type parameter", list, list.Underlying().(*Struct).Field(1).Type())
+	}
+
+	// That of the instance has int in its place.
+	l := pkg.Scope().Lookup("L").Type().Named()
+	st, ok := l.Underlying().(*Struct)
+	if !ok {
+		t.Fatalf("underlying type of %s is %s, want a struct", l, l.Underlying())
+	}
+	if got := st.Field(1).Type(); got != Typ[Int] {
+		t.Errorf("field val of %s has type %s, want int", l, got)
+	}
+	next, ok := st.Field(0).Type().(*Pointer)
+	if !ok || !Identical(next.Elem(), l) {
+		t.Errorf("field next of %s has type %s, want *%s", l, st.Field(0).Type(), l)
+	}
+	if got, want := st.String(), "struct{next *p.List(int); val int}"; got != want {
+		t.Errorf("underlying type of %s is %s, want %s", l, got, want)
+	}
+}
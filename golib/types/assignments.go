@@ -82,7 +82,13 @@ func (check *Checker) initConst(lhs *Const, x *operand) {
 
 	// rhs must be a constant
 	if x.mode != constant_ {
-		check.errorf(x.pos(), "%s is not constant", x)
+		// Values that depend on a type parameter (e.g., int(T(0)))
+		// are not known until instantiation and cannot be constant.
+		if tpar := check.typeParamIn(x.expr); tpar != nil {
+			check.errorf(x.pos(), "%s is not constant (value depends on type parameter %s)", x, tpar.name)
+		} else {
+			check.errorf(x.pos(), "%s is not constant", x)
+		}
 		if lhs.typ == nil {
 			lhs.typ = Typ[Invalid]
 		}
@@ -134,6 +140,35 @@ func (check *Checker) initVar(lhs *Var, x *operand, context string) Type {
 	return x.typ
 }
 
+// typeParamIn returns the first type parameter mentioned in e,
+// either by name or as the type of a variable, or nil.
+func (check *Checker) typeParamIn(e ast.Expr) (tpar *TypeName) {
+	if e == nil {
+		return nil
+	}
+	ast.Inspect(e, func(n ast.Node) bool {
+		if tpar != nil {
+			return false
+		}
+		if id, _ := n.(*ast.Ident); id != nil {
+			if _, obj := check.scope.LookupParent(id.Name, id.Pos()); obj != nil {
+				switch obj := obj.(type) {
+				case *TypeName:
+					if _, ok := obj.typ.(*TypeParam); ok {
+						tpar = obj
+					}
+				case *Var:
+					if tp, _ := obj.typ.(*TypeParam); tp != nil {
+						tpar = tp.obj
+					}
+				}
+			}
+		}
+		return tpar == nil
+	})
+	return
+}
+
 func (check *Checker) assignVar(lhs ast.Expr, x *operand) Type {
 	if x.mode == invalid || x.typ == Typ[Invalid] {
 		check.useLHS(lhs)
@@ -230,6 +265,10 @@ func (check *Checker) initVars(lhs []*Var, orig_rhs []ast.Expr, returnPos token.
 			check.errorf(returnPos, "wrong number of return values (want %d, got %d)", len(lhs), len(rhs))
 			return
 		}
+		if call := multiValueCall(orig_rhs, len(rhs)); call != nil {
+			check.errorf(rhs[0].pos(), "cannot initialize %d variables with %d values (%s returns %d values)", len(lhs), len(rhs), call.Fun, len(rhs))
+			return
+		}
 		check.errorf(rhs[0].pos(), "cannot initialize %d variables with %d values", len(lhs), len(rhs))
 		return
 	}
@@ -253,6 +292,17 @@ func (check *Checker) initVars(lhs []*Var, orig_rhs []ast.Expr, returnPos token.
 	}
 }
 
+// multiValueCall returns the call expression if rhs consists of a single
+// (possibly generic) function call producing n values, with n > 1.
+// Otherwise it returns nil.
+func multiValueCall(rhs []ast.Expr, n int) *ast.CallExpr {
+	if len(rhs) != 1 || n < 2 {
+		return nil
+	}
+	call, _ := unparen(rhs[0]).(*ast.CallExpr)
+	return call
+}
+
 func (check *Checker) assignVars(lhs, orig_rhs []ast.Expr) {
 	rhs, commaOk := check.exprList(orig_rhs, len(lhs) == 2)
 
@@ -264,6 +314,10 @@ func (check *Checker) assignVars(lhs, orig_rhs []ast.Expr) {
 				return
 			}
 		}
+		if call := multiValueCall(orig_rhs, len(rhs)); call != nil {
+			check.errorf(rhs[0].pos(), "cannot assign %d values to %d variables (%s returns %d values)", len(rhs), len(lhs), call.Fun, len(rhs))
+			return
+		}
 		check.errorf(rhs[0].pos(), "cannot assign %d values to %d variables", len(rhs), len(lhs))
 		return
 	}
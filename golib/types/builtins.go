@@ -559,6 +559,17 @@ func (check *Checker) builtin(x *operand, call *ast.CallExpr, id builtinId) (_ b
 			return
 		}
 
+		// The alignment of a type involving type parameters
+		// is not known until instantiation.
+		if IsParameterized(x.typ) {
+			if check.Types != nil {
+				check.recordBuiltinType(call.Fun, makeSig(Typ[Uintptr], x.typ))
+			}
+			x.mode = value
+			x.typ = Typ[Uintptr]
+			break
+		}
+
 		x.mode = constant_
 		x.val = constant.MakeInt64(check.conf.alignof(x.typ))
 		x.typ = Typ[Uintptr]
@@ -603,6 +614,17 @@ func (check *Checker) builtin(x *operand, call *ast.CallExpr, id builtinId) (_ b
 		// TODO(gri) Should we pass x.typ instead of base (and indirect report if derefStructPtr indirected)?
 		check.recordSelection(selx, FieldVal, base, obj, index, false)
 
+		// The offset of a field in a struct involving type
+		// parameters is not known until instantiation.
+		if IsParameterized(base) {
+			if check.Types != nil {
+				check.recordBuiltinType(call.Fun, makeSig(Typ[Uintptr], obj.Type()))
+			}
+			x.mode = value
+			x.typ = Typ[Uintptr]
+			break
+		}
+
 		offs := check.conf.offsetof(base, index)
 		x.mode = constant_
 		x.val = constant.MakeInt64(offs)
@@ -616,6 +638,17 @@ func (check *Checker) builtin(x *operand, call *ast.CallExpr, id builtinId) (_ b
 			return
 		}
 
+		// The size of a type involving type parameters
+		// is not known until instantiation.
+		if IsParameterized(x.typ) {
+			if check.Types != nil {
+				check.recordBuiltinType(call.Fun, makeSig(Typ[Uintptr], x.typ))
+			}
+			x.mode = value
+			x.typ = Typ[Uintptr]
+			break
+		}
+
 		x.mode = constant_
 		x.val = constant.MakeInt64(check.conf.sizeof(x.typ))
 		x.typ = Typ[Uintptr]
@@ -696,7 +729,7 @@ func (check *Checker) applyTypeFunc(f func(Type) Type, x Type) Type {
 		// construct a suitable new type parameter
 		tpar := NewTypeName(token.NoPos, nil /* = Universe pkg */, "<type parameter>", nil)
 		ptyp := check.NewTypeParam(tpar, 0, &emptyInterface) // assigns type to tpar as a side-effect
-		ptyp.bound = &Interface{types: resTypes, allMethods: markComplete, allTypes: resTypes}
+		ptyp.bound = &Interface{types: resTypes, allMethods: markComplete, allTypes: resTypes, typeList: true}
 
 		return ptyp
 	}
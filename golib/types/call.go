@@ -81,7 +81,7 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 			// we must have the correct number of type parameters
 			// TODO(gri) do this in the instantiate call?
 			if n != len(sig.tparams) {
-				check.errorf(args[n-1].pos(), "got %d type arguments but want %d", n, len(sig.tparams))
+				check.errorf(args[n-1].pos(), "got %s but %s expects %d", typeArgCount(n), e.Fun, len(sig.tparams))
 				x.mode = invalid
 				x.expr = e
 				return expression
@@ -101,11 +101,7 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 				poslist[i] = a.pos()
 			}
 
-			// instantiate function signature
-			res := check.instantiate(x.pos(), sig, targs, poslist).(*Signature)
-			assert(res.tparams == nil) // signature is not generic anymore
-			x.typ = res
-			x.mode = value
+			check.funcInst(x, e.Fun, sig, targs, poslist)
 			x.expr = e
 			return expression
 		}
@@ -136,6 +132,62 @@ func (check *Checker) call(x *operand, e *ast.CallExpr) exprKind {
 	}
 }
 
+// funcInst instantiates the generic function x, denoted by fun, with
+// the type arguments targs, written at the positions poslist, and sets
+// x to the instantiated function.
+func (check *Checker) funcInst(x *operand, fun ast.Expr, sig *Signature, targs []Type, poslist []token.Pos) {
+	res := check.instantiate(x.pos(), sig, targs, poslist).(*Signature)
+	assert(res.tparams == nil) // signature is not generic anymore
+	if f := check.funcs[unparen(fun)]; f != nil {
+		check.recordInstance(f, targs)
+	}
+	x.typ = res
+	x.mode = value
+}
+
+// indexedInst type-checks e, an index expression x[targs], as an
+// instantiation if x, the operand for the indexed expression fun,
+// is a generic function or type, and reports whether it is. If so,
+// x is set to the instantiated function or type.
+func (check *Checker) indexedInst(x *operand, e, fun ast.Expr, targs []ast.Expr) bool {
+	switch x.mode {
+	case typexpr:
+		if !isGeneric(x.typ) {
+			return false
+		}
+		x.mode = invalid
+		x.typ = check.typ(e)
+		if x.typ != Typ[Invalid] {
+			x.mode = typexpr
+		}
+
+	case value:
+		sig := x.typ.Signature()
+		if sig == nil || len(sig.tparams) == 0 {
+			return false
+		}
+		x.mode = invalid
+		list := check.typeList(targs)
+		if list == nil {
+			break // error reported by typeList
+		}
+		if n := len(list); n != len(sig.tparams) {
+			check.errorf(targs[n-1].Pos(), "got %s but %s expects %d", typeArgCount(n), fun, len(sig.tparams))
+			break
+		}
+		poslist := make([]token.Pos, len(targs))
+		for i, arg := range targs {
+			poslist[i] = arg.Pos()
+		}
+		check.funcInst(x, fun, sig, list, poslist)
+
+	default:
+		return false
+	}
+	x.expr = e
+	return true
+}
+
 // exprOrTypeList returns a list of operands and reports an error if the
 // list contains a mix of values and types (ignoring invalid operands).
 func (check *Checker) exprOrTypeList(elist []ast.Expr) (xlist []*operand, ok bool) {
@@ -317,6 +369,9 @@ func (check *Checker) arguments(call *ast.CallExpr, sig *Signature, args []*oper
 		rsig = check.instantiate(call.Pos(), sig, targs, nil).(*Signature)
 		assert(rsig.tparams == nil) // signature is not generic anymore
 		check.recordInferred(call, targs, rsig)
+		if f := check.funcs[unparen(call.Fun)]; f != nil {
+			check.recordInstance(f, targs)
+		}
 
 		// Optimization: Only if the parameter list was adjusted do we
 		// need to compute it from the adjusted list; otherwise we can
@@ -386,6 +441,7 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 				x.mode = variable
 				x.typ = exp.typ
 			case *Func:
+				check.recordGenericFunc(e, exp)
 				x.mode = value
 				x.typ = exp.typ
 			case *Builtin:
@@ -439,6 +495,13 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 			// only care about inferring receiver type parameters; to make the inferrence
 			// work, match up pointer-ness of reveiver and argument.
 			arg := x
+			if len(index) > 1 {
+				// m was promoted through embedded fields; the receiver
+				// is the value of the embedded field that declares m.
+				copy := *arg
+				copy.typ = embeddedFieldType(arg.typ, index[:len(index)-1])
+				arg = &copy
+			}
 			if ptrRecv := isPointer(sig.recv.typ); ptrRecv != isPointer(arg.typ) {
 				copy := *arg
 				if ptrRecv {
@@ -511,6 +574,7 @@ func (check *Checker) selector(x *operand, e *ast.SelectorExpr) {
 			// TODO(gri) If we needed to take into account the receiver's
 			// addressability, should we report the type &(x.typ) instead?
 			check.recordSelection(e, MethodVal, x.typ, obj, index, indirect)
+			check.recordGenericFunc(e, obj)
 
 			// TODO(gri) The verification pass below is disabled for now because
 			//           method sets don't match method lookup in some cases.
@@ -85,6 +85,7 @@ type Checker struct {
 	posMap map[*Interface][]token.Pos // maps interface types to lists of embedded interface positions
 	typMap map[string]*Named          // maps an instantiated named type hash to a *Named type
 	pkgCnt map[string]int             // counts number of imported packages with a given name (for better error messages)
+	insts  []Instance                 // list of instantiations of generic functions and types
 
 	// information collected during type-checking of a set of package files
 	// (initialized by Files, valid only for the duration of check.Files;
@@ -95,6 +96,7 @@ type Checker struct {
 	firstErr error                 // first error encountered
 	methods  map[*TypeName][]*Func // maps package scope type names to associated non-blank (non-interface) methods
 	untyped  map[ast.Expr]exprInfo // map of expressions without final type
+	funcs    map[ast.Expr]*Func    // maps expressions denoting generic functions to their objects
 	delayed  []func()              // stack of delayed action segments; segments are processed in FIFO order
 	finals   []func()              // list of final actions; processed at the end of type-checking the current set of files
 	objPath  []Object              // path of object dependencies during type inference (for cycle reporting)
@@ -212,6 +214,7 @@ func (check *Checker) initFiles(files []*ast.File) {
 	check.firstErr = nil
 	check.methods = nil
 	check.untyped = nil
+	check.funcs = nil
 	check.delayed = nil
 	check.finals = nil
 
@@ -351,14 +354,15 @@ func (check *Checker) recordTypeAndValue(x ast.Expr, mode operandMode, typ Type,
 }
 
 func (check *Checker) recordBuiltinType(f ast.Expr, sig *Signature) {
-	// f must be a (possibly parenthesized) identifier denoting a built-in
-	// (built-ins in package unsafe always produce a constant result and
-	// we don't record their signatures, so we don't see qualified idents
-	// here): record the signature for f and possible children.
+	// f must be a (possibly parenthesized, possibly qualified) identifier
+	// denoting a built-in (built-ins in package unsafe produce a constant
+	// result, and we don't record their signatures, unless their argument
+	// involves type parameters): record the signature for f and possible
+	// children.
 	for {
 		check.recordTypeAndValue(f, builtin, sig, nil)
 		switch p := f.(type) {
-		case *ast.Ident:
+		case *ast.Ident, *ast.SelectorExpr:
 			return // we're done
 		case *ast.ParenExpr:
 			f = p.X
@@ -402,6 +406,49 @@ func (check *Checker) recordInferred(call *ast.CallExpr, targs []Type, sig *Sign
 	}
 }
 
+// recordGenericFunc records that e denotes the function obj,
+// if obj is generic.
+func (check *Checker) recordGenericFunc(e ast.Expr, obj *Func) {
+	if sig, _ := obj.typ.(*Signature); sig == nil || len(sig.tparams) == 0 {
+		return
+	}
+	m := check.funcs
+	if m == nil {
+		m = make(map[ast.Expr]*Func)
+		check.funcs = m
+	}
+	m[e] = obj
+}
+
+// recordInstance records the instantiation of the generic
+// function or type obj with targs, unless it was recorded
+// before.
+func (check *Checker) recordInstance(obj Object, targs []Type) {
+	if obj == nil {
+		return
+	}
+L:
+	for _, inst := range check.insts {
+		if inst.Obj != obj || len(inst.Targs) != len(targs) {
+			continue
+		}
+		for i, targ := range targs {
+			if !check.identical(inst.Targs[i], targ) {
+				continue L
+			}
+		}
+		return
+	}
+	check.insts = append(check.insts, Instance{obj, targs})
+}
+
+// Instances returns the instantiations of generic functions and
+// types encountered by the checker so far. Each distinct pair of
+// generic object and type arguments is reported once.
+func (check *Checker) Instances() []Instance {
+	return check.insts
+}
+
 func (check *Checker) recordDef(id *ast.Ident, obj Object) {
 	assert(id != nil)
 	if m := check.Defs; m != nil {
@@ -107,6 +107,10 @@ var tests = [][]string{
 	{"testdata/contracts.go2"},
 	{"testdata/issues.go2"},
 	{"testdata/todos.go2"},
+	{"testdata/conversions.go2"},
+	{"testdata/any.go2"},
+	{"testdata/constants.go2"},
+	{"testdata/indexinst.go2"},
 
 	// Go 2 examples from design doc
 	{"testdata/slices.go2"},
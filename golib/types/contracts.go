@@ -165,8 +165,12 @@ func (check *Checker) contractDecl(obj *Contract, cdecl *ast.ContractSpec) {
 	}
 
 	// complete interfaces
-	for _, bound := range bounds {
-		check.completeInterface(cdecl.Pos(), bound.underlying.(*Interface))
+	for i, bound := range bounds {
+		iface := bound.underlying.(*Interface)
+		check.completeInterface(cdecl.Pos(), iface)
+		if check.conf.ReportUnconstrainedContractParams && iface.Empty() {
+			check.softErrorfCode(tparams[i].pos, UnconstrainedContractParam, "type parameter %s of contract %s is unconstrained (equivalent to interface{})", tparams[i].name, obj.name)
+		}
 	}
 
 	obj.typ = new(contractType) // mark contract as fully set up
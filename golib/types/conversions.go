@@ -14,6 +14,7 @@ func (check *Checker) conversion(x *operand, T Type) {
 	constArg := x.mode == constant_
 
 	var ok bool
+	var cause string
 	switch {
 	case constArg && isConstType(T):
 		// constant conversion
@@ -30,14 +31,47 @@ func (check *Checker) conversion(x *operand, T Type) {
 			// value - let string(codepoint) do the work.
 			x.val = constant.MakeString(string(rune(codepoint)))
 			ok = true
+		default:
+			cause = check.constConversionCause(x, T, t)
+		}
+	case constArg && T.TypeParam() != nil:
+		// constant conversion to a type parameter: the constant
+		// must be convertible to each type in its type list
+		ok = T.TypeParam().Bound().is(func(u Type) bool {
+			t := u.Basic()
+			if t == nil || !isConstType(t) {
+				return x.convertibleTo(check, u)
+			}
+			if representableConst(x.val, check, t, nil) || isInteger(x.typ) && isString(t) {
+				return true
+			}
+			if cause == "" {
+				if cause = check.constConversionCause(x, u, t); cause != "" {
+					cause += check.sprintf(" (in %s)", T)
+				}
+			}
+			return false
+		})
+		if ok {
+			x.mode = value
 		}
 	case x.convertibleTo(check, T):
 		// non-constant conversion
+		if check.conf.ReportIntToString && isInteger(x.typ) && isString(T) {
+			if u := x.typ.Underlying(); u != universeByte && u != universeRune {
+				check.softErrorfCode(x.pos(), IntToStringConversion, "conversion from %s to %s yields a string of one rune, not a string of digits", x.typ, T)
+			}
+		}
 		x.mode = value
 		ok = true
 	}
 
 	if !ok {
+		if cause != "" {
+			check.errorf(x.pos(), "cannot convert %s to %s: %s", x, T, cause)
+			x.mode = invalid
+			return
+		}
 		check.errorf(x.pos(), "cannot convert %s to %s", x, T)
 		x.mode = invalid
 		return
@@ -66,6 +100,23 @@ func (check *Checker) conversion(x *operand, T Type) {
 	x.typ = T
 }
 
+// constConversionCause returns why the numeric constant x is not
+// representable as a value of type T, with underlying type t: either
+// it overflows T, or it must be truncated to be an integer. It returns
+// the empty string for other constants.
+func (check *Checker) constConversionCause(x *operand, T Type, t *Basic) string {
+	if !isNumeric(x.typ) || !isNumeric(t) {
+		return ""
+	}
+	if !isComplex(t) && constant.ToFloat(x.val).Kind() != constant.Float {
+		return "" // complex constant with non-zero imaginary part
+	}
+	if isInteger(t) && constant.ToInt(x.val).Kind() != constant.Int {
+		return check.sprintf("constant %s truncated to %s", x.val, T)
+	}
+	return check.sprintf("constant %s overflows %s", x.val, T)
+}
+
 // TODO(gri) convertibleTo checks if T(x) is valid. It assumes that the type
 // of x is fully known, but that's not the case for say string(1<<s + 1.0):
 // Here, the type of 1<<s + 1.0 will be UntypedFloat which will lead to the
@@ -93,6 +144,17 @@ func (x *operand) convertibleTo(check *Checker, T Type) bool {
 		return true
 	}
 
+	// The same holds for array types of the same length whose element
+	// types are identical for every type in the type list of an element
+	// that is a type parameter.
+	if Va, _ := Vu.(*Array); Va != nil {
+		if Ta, _ := Tu.(*Array); Ta != nil && Va.len == Ta.len {
+			if check.identicalElemIgnoreTags(Va.elem, Ta.elem) {
+				return true
+			}
+		}
+	}
+
 	// "x's type and T are unnamed pointer types and their pointer base types
 	// have identical underlying types if tags are ignored"
 	if V, ok := V.(*Pointer); ok {
@@ -103,6 +165,18 @@ func (x *operand) convertibleTo(check *Checker, T Type) bool {
 		}
 	}
 
+	// "x is a slice, T is a pointer to an array, and the slice and
+	// array types have identical element types"
+	if Vs, _ := Vu.(*Slice); Vs != nil {
+		if Tp, _ := Tu.(*Pointer); Tp != nil {
+			if Ta, _ := Tp.base.Under().(*Array); Ta != nil {
+				if check.identical(Vs.elem, Ta.elem) {
+					return true
+				}
+			}
+		}
+	}
+
 	// "x's type and T are both integer or floating point types"
 	if (isInteger(V) || isFloat(V)) && (isInteger(T) || isFloat(T)) {
 		return true
@@ -136,6 +210,19 @@ func (x *operand) convertibleTo(check *Checker, T Type) bool {
 	return false
 }
 
+// identicalElemIgnoreTags reports whether the array element types x and y
+// are identical if tags are ignored. If x or y is a type parameter, they
+// must be identical for each type in the parameter's type list.
+func (check *Checker) identicalElemIgnoreTags(x, y Type) bool {
+	if p, _ := x.(*TypeParam); p != nil {
+		return p.Bound().is(func(x Type) bool { return check.identicalElemIgnoreTags(x, y) })
+	}
+	if p, _ := y.(*TypeParam); p != nil {
+		return p.Bound().is(func(y Type) bool { return check.identicalElemIgnoreTags(x, y) })
+	}
+	return check.identicalIgnoreTags(x, y)
+}
+
 func isUintptr(typ Type) bool {
 	t := typ.Basic()
 	return t != nil && t.kind == Uintptr
@@ -154,10 +241,20 @@ func isPointer(typ Type) bool {
 	return typ.Pointer() != nil
 }
 
+// isBytesOrRunes reports whether typ is a slice of bytes or runes.
+// An element type that is a type parameter qualifies if every type
+// in its type list is a byte or rune type.
 func isBytesOrRunes(typ Type) bool {
 	if s := typ.Slice(); s != nil {
-		t := s.elem.Basic()
-		return t != nil && (t.kind == Byte || t.kind == Rune)
+		return isByteOrRune(s.elem)
 	}
 	return false
 }
+
+func isByteOrRune(typ Type) bool {
+	if p, _ := typ.Under().(*TypeParam); p != nil {
+		return p.Bound().is(isByteOrRune)
+	}
+	t := typ.Basic()
+	return t != nil && (t.kind == Byte || t.kind == Rune)
+}
@@ -27,7 +27,7 @@ func (check *Checker) declare(scope *Scope, id *ast.Ident, obj Object, pos token
 	// binding."
 	if obj.Name() != "_" {
 		if alt := scope.Insert(obj); alt != nil {
-			check.errorf(obj.Pos(), "%s redeclared in this block", obj.Name())
+			check.errorfCode(obj.Pos(), DuplicateDecl, "%s redeclared in this block", obj.Name())
 			check.reportAltDecl(alt)
 			return
 		}
@@ -412,7 +412,12 @@ func (check *Checker) constDecl(obj *Const, typ, init ast.Expr) {
 		if !isConstType(t) {
 			// don't report an error if the type is an invalid C (defined) type
 			// (issue #22090)
-			if t.Under() != Typ[Invalid] {
+			if _, ok := t.(*TypeParam); ok {
+				// A constant of an instantiated type such as List(int),
+				// whose underlying type is basic, is fine, but the type
+				// of a type parameter is not known until instantiation.
+				check.errorf(typ.Pos(), "invalid constant type %s: a type parameter is not a constant type", t)
+			} else if t.Under() != Typ[Invalid] {
 				check.errorf(typ.Pos(), "invalid constant type %s", t)
 			}
 			obj.typ = Typ[Invalid]
@@ -611,6 +616,11 @@ func (check *Checker) typeDecl(obj *TypeName, tdecl *ast.TypeSpec, def *Named) {
 }
 
 func (check *Checker) collectTypeParams(list *ast.FieldList) (tparams []*TypeName) {
+	if len(list.List) == 0 {
+		check.errorf(list.Pos(), "empty type parameter list")
+		return
+	}
+
 	// Declare type parameters up-front, with empty interface as type bound.
 	// If we use interfaces as type bounds, the scope of type parameters starts at
 	// the beginning of the type parameter list (so we can have mutually recursive
@@ -631,8 +641,7 @@ func (check *Checker) collectTypeParams(list *ast.FieldList) (tparams []*TypeNam
 	}
 
 	setBoundAt := func(at int, bound Type) {
-		assert(IsInterface(bound))
-		tparams[at].typ.(*TypeParam).bound = bound
+		tparams[at].typ.(*TypeParam).setBound(bound)
 	}
 
 	index := 0
@@ -732,6 +741,11 @@ func (check *Checker) contractExpr(x ast.Expr, unused map[*TypeParam]bool) (obj
 				check.recordUse(ident, pname)
 				pname.used = true
 				pkg := pname.imported
+				if check.conf.ContractResolver != nil {
+					if obj = check.conf.ContractResolver(pkg, x.Sel.Name); obj != nil {
+						break
+					}
+				}
 				exp := pkg.scope.Lookup(x.Sel.Name)
 				if exp == nil {
 					if !pkg.fake {
@@ -792,7 +806,7 @@ func (check *Checker) contractExpr(x ast.Expr, unused map[*TypeParam]bool) (obj
 		// it with the actual type arguments targs, and set the bound
 		// for the type parameter.
 		for i, bound := range obj.Bounds {
-			targs[i].(*TypeParam).bound = check.instantiate(call.Args[i].Pos(), bound, targs, nil).(*Named)
+			targs[i].(*TypeParam).setBound(check.instantiate(call.Args[i].Pos(), bound, targs, nil))
 		}
 	}
 
@@ -72,7 +72,7 @@ func (check *Checker) dump(format string, args ...interface{}) {
 	fmt.Println(check.sprintf(format, args...))
 }
 
-func (check *Checker) err(pos token.Pos, msg string, soft bool) {
+func (check *Checker) err(pos token.Pos, code ErrorCode, msg string, soft bool) {
 	// Cheap trick: Don't report errors with messages containing
 	// "invalid operand" or "invalid type" as those tend to be
 	// follow-on errors which don't add useful information. Only
@@ -82,7 +82,19 @@ func (check *Checker) err(pos token.Pos, msg string, soft bool) {
 		return
 	}
 
-	err := Error{check.fset, pos, stripAnnotations(msg), msg, soft}
+	err := Error{check.fset, pos, stripAnnotations(msg), msg, soft, code, false}
+	if code != UnknownError && check.conf.Severity[code] == SeverityWarning {
+		err.Soft = true
+		err.Warn = true
+		if check.conf.Trace {
+			check.trace(pos, "WARNING: %s", msg)
+		}
+		if f := check.conf.Error; f != nil {
+			f(err)
+		}
+		return
+	}
+
 	if check.firstErr == nil {
 		check.firstErr = err
 	}
@@ -99,15 +111,25 @@ func (check *Checker) err(pos token.Pos, msg string, soft bool) {
 }
 
 func (check *Checker) error(pos token.Pos, msg string) {
-	check.err(pos, msg, false)
+	check.err(pos, UnknownError, msg, false)
 }
 
 func (check *Checker) errorf(pos token.Pos, format string, args ...interface{}) {
-	check.err(pos, check.sprintf(format, args...), false)
+	check.err(pos, UnknownError, check.sprintf(format, args...), false)
 }
 
 func (check *Checker) softErrorf(pos token.Pos, format string, args ...interface{}) {
-	check.err(pos, check.sprintf(format, args...), true)
+	check.err(pos, UnknownError, check.sprintf(format, args...), true)
+}
+
+// errorfCode is like errorf but classifies the error with code.
+func (check *Checker) errorfCode(pos token.Pos, code ErrorCode, format string, args ...interface{}) {
+	check.err(pos, code, check.sprintf(format, args...), false)
+}
+
+// softErrorfCode is like softErrorf but classifies the error with code.
+func (check *Checker) softErrorfCode(pos token.Pos, code ErrorCode, format string, args ...interface{}) {
+	check.err(pos, code, check.sprintf(format, args...), true)
 }
 
 func (check *Checker) invalidAST(pos token.Pos, format string, args ...interface{}) {
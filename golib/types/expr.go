@@ -377,6 +377,7 @@ func (check *Checker) updateExprType(x ast.Expr, typ Type, final bool) {
 		*ast.FuncLit,
 		*ast.CompositeLit,
 		*ast.IndexExpr,
+		*ast.IndexListExpr,
 		*ast.SliceExpr,
 		*ast.TypeAssertExpr,
 		*ast.StarExpr,
@@ -1088,9 +1089,11 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 			// be part of a type definition to which the function
 			// body refers. Instead, type-check as soon as possible,
 			// but before the enclosing scope contents changes (#22992).
-			check.later(func() {
-				check.funcBody(decl, "<function literal>", sig, e.Body, iota)
-			})
+			if !check.conf.IgnoreFuncBodies {
+				check.later(func() {
+					check.funcBody(decl, "<function literal>", sig, e.Body, iota)
+				})
+			}
 			x.mode = value
 			x.typ = sig
 		} else {
@@ -1308,7 +1311,18 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 		check.selector(x, e)
 
 	case *ast.IndexExpr:
-		check.expr(x, e.X)
+		check.exprOrType(x, e.X)
+		if x.mode == invalid {
+			check.use(e.Index)
+			goto Error
+		}
+		if check.indexedInst(x, e, e.X, []ast.Expr{e.Index}) {
+			if x.mode == invalid {
+				goto Error
+			}
+			return expression
+		}
+		check.exclude(x, 1<<builtin|1<<typexpr)
 		if x.mode == invalid {
 			check.use(e.Index)
 			goto Error
@@ -1415,6 +1429,26 @@ func (check *Checker) exprInternal(x *operand, e ast.Expr, hint Type) exprKind {
 		check.index(e.Index, length)
 		// ok to continue
 
+	case *ast.IndexListExpr:
+		check.exprOrType(x, e.X)
+		if x.mode == invalid {
+			check.use(e.Indices...)
+			goto Error
+		}
+		if check.indexedInst(x, e, e.X, e.Indices) {
+			if x.mode == invalid {
+				goto Error
+			}
+			return expression
+		}
+		check.use(e.Indices...)
+		if x.mode == typexpr {
+			check.errorf(x.pos(), "%s is not a generic type", x.typ)
+		} else {
+			check.invalidOp(x.pos(), "cannot index %s with more than one index", x)
+		}
+		goto Error
+
 	case *ast.SliceExpr:
 		check.expr(x, e.X)
 		if x.mode == invalid {
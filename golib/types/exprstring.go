@@ -71,6 +71,12 @@ func WriteExpr(buf *bytes.Buffer, x ast.Expr) {
 		WriteExpr(buf, x.Index)
 		buf.WriteByte(']')
 
+	case *ast.IndexListExpr:
+		WriteExpr(buf, x.X)
+		buf.WriteByte('[')
+		writeExprList(buf, x.Indices)
+		buf.WriteByte(']')
+
 	case *ast.SliceExpr:
 		WriteExpr(buf, x.X)
 		buf.WriteByte('[')
@@ -7,6 +7,7 @@ package types
 import (
 	"container/heap"
 	"fmt"
+	"sort"
 )
 
 // initOrder computes the Info.InitOrder for package variables.
@@ -164,6 +165,28 @@ func (check *Checker) reportCycle(cycle []Object) {
 // ----------------------------------------------------------------------------
 // Object dependency graph
 
+// Dependencies returns, for each package-level object checked so far,
+// the objects its declaration directly depends on, in source order.
+// Only constants, variables, and functions are recorded as dependencies;
+// objects without dependencies map to an empty list.
+func (check *Checker) Dependencies() map[Object][]Object {
+	m := make(map[Object][]Object)
+	for obj, d := range check.objMap {
+		if obj.Parent() != check.pkg.scope {
+			continue // method
+		}
+		deps := make([]Object, 0, len(d.deps))
+		for dep := range d.deps {
+			deps = append(deps, dep)
+		}
+		sort.Slice(deps, func(i, j int) bool {
+			return deps[i].order() < deps[j].order()
+		})
+		m[obj] = deps
+	}
+	return m
+}
+
 // A dependency is an object that may be a dependency in an initialization
 // expression. Only constants, variables, and functions can be dependencies.
 // Constants are here because constant expression cycles are reported during
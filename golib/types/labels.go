@@ -35,7 +35,7 @@ func (check *Checker) labels(body *ast.BlockStmt) {
 	// spec: "It is illegal to define a label that is never used."
 	for _, obj := range all.elems {
 		if lbl := obj.(*Label); !lbl.used {
-			check.softErrorf(lbl.pos, "label %s declared but not used", lbl.name)
+			check.softErrorfCode(lbl.pos, UnusedLabel, "label %s declared but not used", lbl.name)
 		}
 	}
 }
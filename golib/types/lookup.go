@@ -433,6 +433,16 @@ func derefStructPtr(typ Type) Type {
 	return typ
 }
 
+// embeddedFieldType returns the type of the embedded field reached
+// from typ by following the sequence of field indices in index.
+func embeddedFieldType(typ Type, index []int) Type {
+	for _, i := range index {
+		typ, _ = deref(typ)
+		typ = typ.Struct().fields[i].typ
+	}
+	return typ
+}
+
 // concat returns the result of concatenating list and i.
 // The result does not share its underlying array with list.
 func concat(list []int, i int) []int {
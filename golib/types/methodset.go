@@ -69,6 +69,9 @@ var emptyMethodSet MethodSet
 
 // NewMethodSet returns the method set for the given type T.
 // It always returns a non-nil method set, even if it is empty.
+// The method set of a type parameter, or of a pointer to one, is
+// that of its bound: the methods that may be called on a value of
+// the type parameter in the body of the generic function or type.
 func NewMethodSet(T Type) *MethodSet {
 	// WARNING: The code in this function is extremely subtle - do not modify casually!
 	//          This function and lookupFieldOrMethod should be kept in sync.
@@ -76,7 +79,15 @@ func NewMethodSet(T Type) *MethodSet {
 	// method set up to the current depth, allocated lazily
 	var base methodSet
 
-	typ, isPtr := derefUnpack(T)
+	typ, isPtr := deref(T)
+
+	// If we have a type parameter, use its bound and ignore isPtr,
+	// as lookupFieldOrMethod does: the methods of the bound may be
+	// called on a pointer to a value of type parameter type.
+	if tpar, _ := typ.(*TypeParam); tpar != nil {
+		typ = tpar.Bound()
+		isPtr = false
+	}
 
 	// *typ where typ is an interface has no methods.
 	if isPtr && IsInterface(typ) {
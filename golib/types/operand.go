@@ -270,6 +270,26 @@ func (x *operand) assignableTo(check *Checker, T Type, reason *string) bool {
 		return true
 	}
 
+	// T is a type parameter, x is nil or V is not a named type,
+	// and x is assignable to each type in T's type list
+	if Tp, _ := T.(*TypeParam); Tp != nil {
+		if _, ok := V.(*TypeParam); x.isNil() || !ok && !isNamed(V) {
+			return Tp.Bound().is(func(T Type) bool { return x.assignableTo(check, T, nil) })
+		}
+	}
+
+	// V is a type parameter, T is not a named type or a type
+	// parameter, and a value of each type in V's type list is
+	// assignable to T
+	if Vp, _ := V.(*TypeParam); Vp != nil {
+		if _, ok := T.(*TypeParam); !ok && !isNamed(T) {
+			return Vp.Bound().is(func(V Type) bool {
+				y := operand{mode: value, typ: V}
+				return y.assignableTo(check, T, nil)
+			})
+		}
+	}
+
 	// x is a bidirectional channel value, T is a channel
 	// type, x's type V and T have identical element types,
 	// and at least one of V or T is not a named type
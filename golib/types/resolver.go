@@ -313,7 +313,7 @@ func (check *Checker) collectObjects() {
 									// the object may be imported into more than one file scope
 									// concurrently. See issue #32154.)
 									if alt := fileScope.Insert(obj); alt != nil {
-										check.errorf(s.Name.Pos(), "%s redeclared in this block", obj.Name())
+										check.errorfCode(s.Name.Pos(), DuplicateDecl, "%s redeclared in this block", obj.Name())
 										check.reportAltDecl(alt)
 									}
 								}
@@ -687,9 +687,9 @@ func (check *Checker) unusedImports() {
 					path := obj.imported.path
 					base := pkgName(path)
 					if obj.name == base {
-						check.softErrorf(obj.pos, "%q imported but not used", path)
+						check.softErrorfCode(obj.pos, UnusedImport, "%q imported but not used", path)
 					} else {
-						check.softErrorf(obj.pos, "%q imported but not used as %s", path, obj.name)
+						check.softErrorfCode(obj.pos, UnusedImport, "%q imported but not used as %s", path, obj.name)
 					}
 				}
 			}
@@ -699,7 +699,7 @@ func (check *Checker) unusedImports() {
 	// check use of dot-imported packages
 	for _, unusedDotImports := range check.unusedDotImports {
 		for pkg, pos := range unusedDotImports {
-			check.softErrorf(pos, "%q imported but not used", pkg.path)
+			check.softErrorfCode(pos, UnusedImport, "%q imported but not used", pkg.path)
 		}
 	}
 }
@@ -52,6 +52,15 @@ func (m *substMap) lookup(typ *TypeParam) Type {
 	return typ
 }
 
+// typeArgCount describes n type arguments, as in "1 type argument"
+// or "2 type arguments".
+func typeArgCount(n int) string {
+	if n == 1 {
+		return "1 type argument"
+	}
+	return fmt.Sprintf("%d type arguments", n)
+}
+
 func (check *Checker) instantiate(pos token.Pos, typ Type, targs []Type, poslist []token.Pos) (res Type) {
 	if check.conf.Trace {
 		check.trace(pos, "-- instantiating %s with %s", typ, typeListString(targs))
@@ -73,8 +82,16 @@ func (check *Checker) instantiate(pos token.Pos, typ Type, targs []Type, poslist
 	switch t := typ.(type) {
 	case *Named:
 		tparams = t.tparams
+		// the number of supplied types must match the number of type parameters
+		if len(targs) != len(tparams) {
+			check.errorf(pos, "got %s but %s expects %d", typeArgCount(len(targs)), t.obj.name, len(tparams))
+			return Typ[Invalid]
+		}
 	case *Signature:
 		tparams = t.tparams
+		// The callers check the number of type arguments for
+		// functions, as only they know the function's name.
+		assert(len(targs) == len(tparams))
 		defer func() {
 			// If we had an unexpected failure somewhere don't
 			// panic below when asserting res.(*Signature).
@@ -99,13 +116,6 @@ func (check *Checker) instantiate(pos token.Pos, typ Type, targs []Type, poslist
 
 	}
 
-	// the number of supplied types must match the number of type parameters
-	if len(targs) != len(tparams) {
-		// TODO(gri) provide better error message
-		check.errorf(pos, "got %d arguments but %d type parameters", len(targs), len(tparams))
-		return Typ[Invalid]
-	}
-
 	if len(tparams) == 0 {
 		return typ // nothing to do (minor optimization)
 	}
@@ -134,62 +144,71 @@ func (check *Checker) instantiate(pos token.Pos, typ Type, targs []Type, poslist
 		// the parameterized type.
 		iface = check.subst(pos, iface, smap).(*Interface)
 
-		// targ must implement iface (methods)
-		//
-		// Assume targ is addressable, per the draft design: "In a generic function
-		// body all method calls will be pointer method calls. If necessary, the
-		// function body will insert temporary variables, not seen by the user, in
-		// order to get an addressable variable to use to call the method."
-		//
-		// TODO(gri) Instead of the addressable (= true) flag, could we encode the
-		// same information by making targ a pointer type (and then get rid of the
-		// need for that extra flag)?
-		if m, _ := check.missingMethod(targ, true, iface, true); m != nil {
-			// TODO(gri) needs to print updated name to avoid major confusion in error message!
-			//           (print warning for now)
-			// check.softErrorf(pos, "%s does not satisfy %s (warning: name not updated) = %s (missing method %s)", targ, tpar.bound, iface, m)
-			if m.name == "==" {
-				// We don't want to report "missing method ==".
-				check.softErrorf(pos, "%s does not satisfy comparable", targ)
-			} else {
-				check.softErrorf(pos, "%s does not satisfy %s (missing method %s)", targ, tpar.bound, m.name)
-			}
+		if msg := check.unsatisfied(targ, tpar.bound, iface); msg != "" {
+			check.softErrorfCode(pos, UnsatisfiedConstraint, "%s", msg)
 			break
 		}
+	}
 
-		// targ's underlying type must also be one of the interface types listed, if any
-		if len(iface.allTypes) == 0 {
-			break // nothing to do
-		}
-		// len(iface.allTypes) > 0
-
-		// If targ is itself a type parameter, each of its possible types, but at least one, must be in the
-		// list of iface types (i.e., the targ type list must be a non-empty subset of the iface types).
-		if targ := targ.TypeParam(); targ != nil {
-			targBound := targ.Bound()
-			if len(targBound.allTypes) == 0 {
-				check.softErrorf(pos, "%s does not satisfy %s (%s has no type constraints)", targ, tpar.bound, targ)
-				break
-			}
-			for _, t := range targBound.allTypes {
-				if !iface.includes(t.Under()) {
-					// TODO(gri) match this error message with the one below (or vice versa)
-					check.softErrorf(pos, "%s does not satisfy %s (%s type constraint %s not found in %s)", targ, tpar.bound, targ, t, iface.allTypes)
-					break
-				}
-			}
-			break
+	return check.subst(pos, typ, smap)
+}
+
+// unsatisfied returns a message describing why the type argument
+// targ does not satisfy iface, the bound of its type parameter
+// instantiated with the type arguments, or "" if targ satisfies it.
+// The message refers to the bound as bound, such as the uninstantiated
+// bound of the type parameter.
+func (check *Checker) unsatisfied(targ Type, bound interface{}, iface *Interface) string {
+	// targ must implement iface (methods)
+	//
+	// Assume targ is addressable, per the draft design: "In a generic function
+	// body all method calls will be pointer method calls. If necessary, the
+	// function body will insert temporary variables, not seen by the user, in
+	// order to get an addressable variable to use to call the method."
+	//
+	// TODO(gri) Instead of the addressable (= true) flag, could we encode the
+	// same information by making targ a pointer type (and then get rid of the
+	// need for that extra flag)?
+	if m, _ := check.missingMethod(targ, true, iface, true); m != nil {
+		// TODO(gri) needs to print updated name to avoid major confusion in error message!
+		if m.name == "==" {
+			// We don't want to report "missing method ==".
+			return check.sprintf("%s does not satisfy comparable", targ)
 		}
+		return check.sprintf("%s does not satisfy %s (missing method %s)", targ, bound, m.name)
+	}
 
-		// Otherwise, targ's underlying type must also be one of the interface types listed, if any.
-		// TODO(gri) must it be the underlying type, or should it just be the type? (spec question)
-		if !iface.includes(targ.Under()) {
-			check.softErrorf(pos, "%s does not satisfy %s (%s not found in %s)", targ, tpar.bound, targ.Under(), iface.allTypes)
-			break
+	// targ's underlying type must also be one of the interface types listed, if any
+	if !iface.typeList {
+		return "" // nothing else to check
+	}
+	if len(iface.allTypes) == 0 {
+		return check.sprintf("%s does not satisfy %s (type list is empty)", targ, bound)
+	}
+	// len(iface.allTypes) > 0
+
+	// If targ is itself a type parameter, each of its possible types, but at least one, must be in the
+	// list of iface types (i.e., the targ type list must be a non-empty subset of the iface types).
+	if targ := targ.TypeParam(); targ != nil {
+		targBound := targ.Bound()
+		if len(targBound.allTypes) == 0 {
+			return check.sprintf("%s does not satisfy %s (%s has no type constraints)", targ, bound, targ)
+		}
+		for _, t := range targBound.allTypes {
+			if !iface.includes(t.Under()) {
+				// TODO(gri) match this error message with the one below (or vice versa)
+				return check.sprintf("%s does not satisfy %s (%s type constraint %s not found in %s)", targ, bound, targ, t, iface.allTypes)
+			}
 		}
+		return ""
 	}
 
-	return check.subst(pos, typ, smap)
+	// Otherwise, targ's underlying type must also be one of the interface types listed, if any.
+	// TODO(gri) must it be the underlying type, or should it just be the type? (spec question)
+	if !iface.includes(targ.Under()) {
+		return check.sprintf("%s does not satisfy %s (%s not found in %s)", targ, bound, targ.Under(), iface.allTypes)
+	}
+	return ""
 }
 
 // subst returns the type typ with its type parameters tpars replaced by
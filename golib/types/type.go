@@ -295,6 +295,26 @@ func (s *Signature) TParams() []*TypeName { return s.tparams }
 // SetTParams sets the type parameters of signature s.
 func (s *Signature) SetTParams(tparams []*TypeName) { s.tparams = tparams }
 
+// A TParamBound pairs the name of a type parameter with its bound.
+type TParamBound struct {
+	Name  string
+	Bound Type // *Named or *Interface; underlying type is always *Interface
+}
+
+// TParamsWithBounds returns the names and bounds of the type parameters
+// of signature s, or nil. The bound of a type parameter constrained by
+// a contract is the contract's bound for that parameter.
+func (s *Signature) TParamsWithBounds() []TParamBound {
+	if len(s.tparams) == 0 {
+		return nil
+	}
+	list := make([]TParamBound, len(s.tparams))
+	for i, tpar := range s.tparams {
+		list[i] = TParamBound{tpar.name, tpar.typ.(*TypeParam).bound}
+	}
+	return list
+}
+
 // Params returns the parameters of signature s, or nil.
 func (s *Signature) Params() *Tuple { return s.params }
 
@@ -312,6 +332,7 @@ type Interface struct {
 
 	allMethods []*Func // ordered list of methods declared with or embedded in this interface (TODO(gri): replace with mset)
 	allTypes   []Type  // list of types declared with or embedded in this interface
+	typeList   bool    // whether allTypes restricts the permitted types; it may be empty
 
 	aType
 }
@@ -425,7 +446,7 @@ func (t *Interface) Method(i int) *Func { t.assertCompleteness(); return t.allMe
 func (t *Interface) Empty() bool {
 	if t.allMethods != nil {
 		// interface is complete - quick test
-		return len(t.allMethods) == 0 && len(t.allTypes) == 0
+		return len(t.allMethods) == 0 && !t.typeList
 	}
 	return empty(t, nil)
 }
@@ -466,6 +487,21 @@ func (t *Interface) includes(typ Type) bool {
 	return false
 }
 
+// intersectTypes returns the types in the type list x that are
+// also in the type list y.
+func intersectTypes(x, y []Type) []Type {
+	var r []Type
+	for _, xt := range x {
+		for _, yt := range y {
+			if Identical(xt, yt) {
+				r = append(r, xt)
+				break
+			}
+		}
+	}
+	return r
+}
+
 // Complete computes the interface's method set. It must be called by users of
 // NewInterfaceType and NewInterface after the interface's embedded types are
 // fully defined and before using the interface type in any way other than to
@@ -498,16 +534,24 @@ func (t *Interface) Complete() *Interface {
 		addMethod(m, true)
 	}
 
-	var types []Type
-	types = append(types, t.types...)
-
+	// A type must be in every type list, if any, to be permitted.
+	// The intersection may be empty, in which case no type is.
+	types := t.types
+	typeList := len(types) > 0
 	for _, typ := range t.embeddeds {
 		typ := typ.Interface()
 		typ.Complete()
 		for _, m := range typ.allMethods {
 			addMethod(m, false)
 		}
-		types = append(types, typ.types...)
+		switch {
+		case !typ.typeList:
+		case !typeList:
+			types = typ.allTypes
+			typeList = true
+		default:
+			types = intersectTypes(types, typ.allTypes)
+		}
 	}
 
 	for i := 0; i < len(todo); i += 2 {
@@ -523,6 +567,7 @@ func (t *Interface) Complete() *Interface {
 		t.allMethods = methods
 	}
 	t.allTypes = types
+	t.typeList = typeList
 
 	return t
 }
@@ -638,6 +683,10 @@ func (t *Named) TArgs() []Type { return t.targs }
 // SetTArgs sets the type arguments of Named.
 func (t *Named) SetTArgs(args []Type) { t.targs = args }
 
+// IsInstance reports whether the named type t was produced by instantiating
+// a parameterized type, that is whether it has type arguments.
+func (t *Named) IsInstance() bool { return t.targs != nil }
+
 // NumMethods returns the number of explicit methods whose receiver is named type t.
 func (t *Named) NumMethods() int { return len(t.methods) }
 
@@ -682,12 +731,21 @@ func (check *Checker) NewTypeParam(obj *TypeName, index int, bound Type) *TypePa
 	return typ
 }
 
+// Bound returns the interface that bounds t. The bound comes
+// from the constraint of t, which may be an interface or a contract.
+// It is the empty interface if t is unconstrained.
 func (t *TypeParam) Bound() *Interface {
 	iface := t.bound.Interface()
 	iface.Complete() // TODO(gri) should we use check.completeInterface instead?
 	return iface
 }
 
+// setBound sets the bound of t. The bound must be an interface.
+func (t *TypeParam) setBound(bound Type) {
+	assert(IsInterface(bound))
+	t.bound = bound
+}
+
 // An instance represents an instantiated generic type syntactically
 // (without expanding the instantiation). Type instances appear only
 // during type-checking and are replaced by their fully instantiated
@@ -775,7 +833,12 @@ func (t *Signature) Underlying() Type { return t }
 func (t *Interface) Underlying() Type { return t }
 func (t *Map) Underlying() Type       { return t }
 func (t *Chan) Underlying() Type      { return t }
-func (t *Named) Underlying() Type     { return t.underlying }
+
+// Underlying returns the underlying type of t. The underlying type of
+// an instance is that of the parameterized type with the type arguments
+// substituted for the type parameters.
+func (t *Named) Underlying() Type { return t.underlying }
+
 func (t *TypeParam) Underlying() Type { return t }
 func (t *instance) Underlying() Type  { return t }
 
@@ -32,7 +32,7 @@ func (check *Checker) ident(x *operand, e *ast.Ident, def *Named, wantType bool)
 		if e.Name == "_" {
 			check.errorf(e.Pos(), "cannot use _ as value or type")
 		} else {
-			check.errorf(e.Pos(), "undeclared name: %s", e.Name)
+			check.errorfCode(e.Pos(), UndeclaredName, "undeclared name: %s", e.Name)
 		}
 		return
 	}
@@ -110,6 +110,7 @@ func (check *Checker) ident(x *operand, e *ast.Ident, def *Named, wantType bool)
 		unreachable() // handled earlier
 
 	case *Func:
+		check.recordGenericFunc(e, obj)
 		check.addDeclDep(obj)
 		x.mode = value
 
@@ -293,7 +294,18 @@ func (check *Checker) funcType(sig *Signature, recvPar *ast.FieldList, ftyp *ast
 	params, variadic := check.collectParams(scope, ftyp.Params, nil, true)
 	results, _ := check.collectParams(scope, ftyp.Results, nil, false)
 	scope.Squash(func(obj, alt Object) {
-		check.errorf(obj.Pos(), "%s redeclared in this block", obj.Name())
+		if isTypeParamName(check.scope, obj.Name()) {
+			// The name would be ambiguous in the signature and body.
+			kind := "receiver"
+			if containsVar(results, obj) {
+				kind = "result"
+			} else if containsVar(params, obj) {
+				kind = "parameter"
+			}
+			check.errorfCode(obj.Pos(), DuplicateDecl, "cannot use type parameter name %s as %s name", obj.Name(), kind)
+			return
+		}
+		check.errorfCode(obj.Pos(), DuplicateDecl, "%s redeclared in this block", obj.Name())
 		check.reportAltDecl(alt)
 	})
 
@@ -414,46 +426,13 @@ func (check *Checker) typInternal(e ast.Expr, def *Named) (T Type) {
 		}
 
 	case *ast.CallExpr:
-		b := check.genericType(e.Fun, true) // TODO(gri) what about cycles?
-		if b == Typ[Invalid] {
-			return b // error already reported
-		}
-		base := b.Named()
-		if base == nil {
-			unreachable() // should have been caught by genericType
-		}
-
-		// create a new type Instance rather than instantiate the type
-		// TODO(gri) should do argument number check here rather than
-		// when instantiating the type?
-		typ := new(instance)
-		def.setUnderlying(typ)
-
-		typ.check = check
-		typ.pos = e.Pos()
-		typ.base = base
-
-		// evaluate arguments (always)
-		typ.targs = check.typeList(e.Args)
-		if typ.targs == nil {
-			def.setUnderlying(Typ[Invalid]) // avoid later errors due to lazy instantiation
-			return Typ[Invalid]
-		}
-
-		// determine argument positions (for error reporting)
-		typ.poslist = make([]token.Pos, len(e.Args))
-		for i, arg := range e.Args {
-			typ.poslist[i] = arg.Pos()
-		}
+		return check.instantiatedType(e.Fun, e.Args, def)
 
-		// make sure we check instantiation works at least once
-		// and that the resulting type is valid
-		check.atEnd(func() {
-			t := typ.expand()
-			check.validType(t, nil)
-		})
+	case *ast.IndexExpr:
+		return check.instantiatedType(e.X, []ast.Expr{e.Index}, def)
 
-		return typ
+	case *ast.IndexListExpr:
+		return check.instantiatedType(e.X, e.Indices, def)
 
 	case *ast.ParenExpr:
 		// Generic types must be instantiated before they can be used in any form.
@@ -550,6 +529,56 @@ func (check *Checker) typInternal(e ast.Expr, def *Named) (T Type) {
 	return typ
 }
 
+// instantiatedType type-checks the instantiation of the generic type x
+// with the type arguments targs, written as x(targs) or x[targs], and
+// returns the instantiated type.
+// If def != nil, def is the type being declared (see Checker.definedType).
+func (check *Checker) instantiatedType(x ast.Expr, targs []ast.Expr, def *Named) Type {
+	b := check.genericType(x, true) // TODO(gri) what about cycles?
+	if b == Typ[Invalid] {
+		return b // error already reported
+	}
+	base := b.Named()
+	if base == nil {
+		unreachable() // should have been caught by genericType
+	}
+
+	// create a new type Instance rather than instantiate the type
+	// TODO(gri) should do argument number check here rather than
+	// when instantiating the type?
+	typ := new(instance)
+	def.setUnderlying(typ)
+
+	typ.check = check
+	typ.pos = x.Pos()
+	typ.base = base
+
+	// evaluate arguments (always)
+	typ.targs = check.typeList(targs)
+	if typ.targs == nil {
+		def.setUnderlying(Typ[Invalid]) // avoid later errors due to lazy instantiation
+		return Typ[Invalid]
+	}
+
+	// determine argument positions (for error reporting)
+	typ.poslist = make([]token.Pos, len(targs))
+	for i, arg := range targs {
+		typ.poslist[i] = arg.Pos()
+	}
+
+	// make sure we check instantiation works at least once
+	// and that the resulting type is valid
+	check.atEnd(func() {
+		t := typ.expand()
+		check.validType(t, nil)
+		if t != Typ[Invalid] {
+			check.recordInstance(base.obj, typ.targs)
+		}
+	})
+
+	return typ
+}
+
 // typeOrNil type-checks the type expression (or nil value) e
 // and returns the typ of e, or nil.
 // If e is neither a type nor nil, typOrNil returns Typ[Invalid].
@@ -681,6 +710,25 @@ func (check *Checker) collectParams(scope *Scope, list *ast.FieldList, type0 ast
 	return
 }
 
+// isTypeParamName reports whether name is declared in scope as a type parameter.
+func isTypeParamName(scope *Scope, name string) bool {
+	if tn, _ := scope.Lookup(name).(*TypeName); tn != nil {
+		_, ok := tn.typ.(*TypeParam)
+		return ok
+	}
+	return false
+}
+
+// containsVar reports whether list contains v.
+func containsVar(list []*Var, v Object) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
 func (check *Checker) declareInSet(oset *objset, pos token.Pos, obj Object) bool {
 	if alt := oset.insert(obj); alt != nil {
 		check.errorf(pos, "%s redeclared", obj.Name())
@@ -731,7 +779,14 @@ func (check *Checker) interfaceType(ityp *Interface, iface *ast.InterfaceType, d
 		} else {
 			// We have an embedded type. completeInterface will
 			// eventually verify that we have an interface.
-			ityp.embeddeds = append(ityp.embeddeds, check.typ(f.Type))
+			typ := check.typ(f.Type)
+			if tpar, _ := typ.(*TypeParam); tpar != nil {
+				// The type argument for tpar need not be an
+				// interface, so embed what is known of it:
+				// the methods and types of its bound.
+				typ = tpar.bound
+			}
+			ityp.embeddeds = append(ityp.embeddeds, typ)
 			check.posMap[ityp] = append(check.posMap[ityp], f.Type.Pos())
 		}
 	}
@@ -829,8 +884,10 @@ func (check *Checker) completeInterface(pos token.Pos, ityp *Interface) {
 
 	// collect types
 	// TODO(gri) report error for multiple explicitly declared identical types
-	var types []Type
-	types = append(types, ityp.types...)
+	// A type must be in every type list, if any, to be permitted.
+	// The intersection may be empty, in which case no type is.
+	types := ityp.types
+	typeList := len(types) > 0
 
 	posList := check.posMap[ityp]
 	for i, typ := range ityp.embeddeds {
@@ -847,7 +904,16 @@ func (check *Checker) completeInterface(pos token.Pos, ityp *Interface) {
 		for _, m := range etyp.allMethods {
 			addMethod(pos, m, false) // use embedding position pos rather than m.pos
 		}
-		types = append(types, etyp.allTypes...)
+		switch {
+		case !etyp.typeList:
+		case !typeList:
+			types = etyp.allTypes
+			typeList = true
+		default:
+			if types = intersectTypes(types, etyp.allTypes); len(types) == 0 {
+				check.errorf(pos, "type list of %s has no types in common with the other type lists", typ)
+			}
+		}
 	}
 
 	if methods != nil {
@@ -855,6 +921,7 @@ func (check *Checker) completeInterface(pos token.Pos, ityp *Interface) {
 		ityp.allMethods = methods
 	}
 	ityp.allTypes = types
+	ityp.typeList = typeList
 }
 
 // byUniqueTypeName named type lists can be sorted by their unique type names.
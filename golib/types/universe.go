@@ -76,6 +76,9 @@ func defPredeclaredTypes() {
 		def(NewTypeName(token.NoPos, nil, t.name, t))
 	}
 
+	// type any = interface{}
+	def(NewTypeName(token.NoPos, nil, "any", &emptyInterface))
+
 	// Error has a nil package in its qualified name since it is in no package
 	res := NewVar(token.NoPos, nil, "", Typ[String])
 	sig := &Signature{results: NewTuple(res)}